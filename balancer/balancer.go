@@ -3,64 +3,93 @@ package balancer
 import (
 	"context"
 	"fmt"
-	"strings"
-	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
 	"github.com/DoOR-Team/goutils/balancer/balancer_policy"
-	"github.com/DoOR-Team/goutils/balancer/registry/k8s"
 	"github.com/DoOR-Team/goutils/grpc_http"
 	"github.com/DoOR-Team/goutils/log"
 	"github.com/DoOR-Team/goutils/waitgroup"
 )
 
-func NewRPCClientWithUserInfo(ctx context.Context, address string, userInfoKey string, userInfo interface{}) *grpc.ClientConn {
-	useBalance := false
-
-	var env, serviceName string
-	attrs := strings.Split(address, ".")
-	if len(attrs) >= 2 {
-		env = attrs[1]
-		serviceName = attrs[0]
-		if env == "daily" || env == "production" {
+// 地址为 scheme:///endpoint 或者通过 WithResolver 指定 resolver 时使用对应的 resolver，
+// 否则 svc.daily:port、svc.production:port 使用 k8s resolver，其他地址直接连接
+//
+//	conn, err := balancer.NewRPCClient("static:///127.0.0.1:9001,127.0.0.1:9002",
+//		balancer.WithBalancerPolicy(balancer_policy.WeightedRoundRobin),
+//		balancer.WithTimeout(3*time.Second),
+//	)
+//
+// 连接在进程退出时由 waitgroup 关闭
+func NewRPCClient(address string, opts ...Option) (*grpc.ClientConn, error) {
+	return dial(context.Background(), address, opts...)
+}
 
-			useBalance = true
-		}
+func dial(ctx context.Context, address string, opts ...Option) (*grpc.ClientConn, error) {
+	options := clientOptions{}
+	for _, o := range opts {
+		o(&options)
 	}
 
 	log.Println("address :", address)
-	port := address[strings.Index(address, ":"):]
-	// rr := grpc.RoundRobin(grpcsrvlb.New(srv.NewGoResolver(port, etcdHost, addr, 2*time.Second)))
-	// rr := grpc.RoundRobin(grpcsrvlb.New(NewResolver(port, env, serviceName, 2*time.Second)))
-
 	var opt []grpc.DialOption
 
-	if useBalance {
-		log.Info("使用load balancer 初始化：", address)
-		k8s.RegisterResolver("k8s", port, env, serviceName, time.Second*5)
-		opt = append(opt, grpc.WithBalancerName(balancer_policy.RoundRobin))
-		address = "k8s:///" + address
-		log.Info("address changing to", address)
+	address, scheme, err := resolveTarget(address, options.resolver)
+	if err != nil {
+		return nil, err
+	}
+	policy := options.policy
+	if policy == "" && scheme != "" {
+		policy = balancer_policy.RoundRobin
+	}
+	if policy != "" {
+		log.Infof("使用load balancer 初始化：%s, policy: %s", address, policy)
+		opt = append(opt, grpc.WithBalancerName(policy))
 	}
-	opt = append(opt, grpc.WithInsecure())
-	//	opt = append(opt, grpc.WithDefaultCallOptions(grpc.FailFast(false)))
-	// opt = append(opt, grpc.WithWaitForHandshake())
 
-	opt = append(opt, grpc.WithUnaryInterceptor(grpc_http.ClientInterceptor))
-	if userInfoKey != "" && userInfo != nil {
-		opt = append(opt, grpc.WithUnaryInterceptor(grpc_http.DeliverUserInfoClientInterceptorFactory(userInfoKey, userInfo)))
+	if options.tls != nil {
+		opt = append(opt, grpc.WithTransportCredentials(credentials.NewTLS(options.tls)))
+	} else {
+		opt = append(opt, grpc.WithInsecure())
+	}
+	if options.keepalive != nil {
+		opt = append(opt, grpc.WithKeepaliveParams(*options.keepalive))
+	}
+	if options.maxMsgSize > 0 {
+		opt = append(opt, grpc.WithDefaultCallOptions(
+			grpc.MaxCallRecvMsgSize(options.maxMsgSize),
+			grpc.MaxCallSendMsgSize(options.maxMsgSize),
+		))
+	}
+
+	// 多次 grpc.WithUnaryInterceptor 只有最后一个生效，这里统一串起来
+	var unary []grpc.UnaryClientInterceptor
+	if options.timeout > 0 {
+		unary = append(unary, timeoutInterceptor(options.timeout))
+	}
+	if grpc_http.ClientInterceptor != nil {
+		unary = append(unary, grpc_http.ClientInterceptor)
+	}
+	if options.userInfoKey != "" && options.userInfo != nil {
+		unary = append(unary, grpc_http.DeliverUserInfoClientInterceptorFactory(options.userInfoKey, options.userInfo))
+	}
+	unary = append(unary, options.unary...)
+	opt = append(opt, grpc.WithChainUnaryInterceptor(unary...))
+	if len(options.stream) > 0 {
+		opt = append(opt, grpc.WithChainStreamInterceptor(options.stream...))
+	}
+	opt = append(opt, options.dialOptions...)
+
+	if options.dialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.dialTimeout)
+		defer cancel()
+		opt = append(opt, grpc.WithBlock())
 	}
-	/*	opt = append(opt, grpc.WithKeepaliveParams(keepalive.ClientParameters{
-			Time:                50 * time.Millisecond,
-			Timeout:             1 * time.Millisecond,
-			PermitWithoutStream: true,
-		}))
-	*/
 	conn, err := grpc.DialContext(ctx, address, opt...)
 	if err != nil {
-		// log.Panic(err)
-		log.Fatal(err)
+		return nil, fmt.Errorf("grpc dial %s: %v", address, err)
 	}
 	log.Info("初始化 grpc Dial success:", conn, err)
 	_ = waitgroup.AddModAndWrapServer(fmt.Sprintf("GRPC_Client(%s)", address), &waitgroup.Cli{
@@ -69,10 +98,15 @@ func NewRPCClientWithUserInfo(ctx context.Context, address string, userInfoKey s
 		},
 	})
 
-	return conn
+	return conn, nil
 }
 
-func NewRPCClient(address string) *grpc.ClientConn {
-	ctx, _ := context.WithTimeout(context.Background(), time.Second*100)
-	return NewRPCClientWithUserInfo(ctx, address, "", nil)
+// Deprecated: 使用 NewRPCClient(address, WithUserInfo(userInfoKey, userInfo))，连接失败时这里会直接结束进程
+func NewRPCClientWithUserInfo(ctx context.Context, address string, userInfoKey string, userInfo interface{}, opts ...Option) *grpc.ClientConn {
+	opts = append(opts, WithUserInfo(userInfoKey, userInfo))
+	conn, err := dial(ctx, address, opts...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return conn
 }
@@ -1,11 +1,16 @@
 package balancer_policy
 
 import (
+	"context"
 	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
 
 	"google.golang.org/grpc/balancer"
 	"google.golang.org/grpc/balancer/base"
 	"google.golang.org/grpc/grpclog"
+	"google.golang.org/grpc/metadata"
 
 	"github.com/DoOR-Team/goutils/balancer/common"
 )
@@ -14,17 +19,56 @@ const ConsistentHash = "consistent_hash_x"
 
 var DefaultConsistentHashKey = "consistent-hash"
 
-func InitConsistentHashBuilder(consistanceHashKey string) {
-	balancer.Register(newConsistentHashBuilder(consistanceHashKey))
+type consistentHashOptions struct {
+	fallback   base.PickerBuilder
+	loadFactor float64
+}
+
+type ConsistentHashOption func(*consistentHashOptions)
+
+// 请求里没有 hash key 时使用的 picker，默认为 weighted_round_robin_x
+func WithHashFallback(builder base.PickerBuilder) ConsistentHashOption {
+	return func(o *consistentHashOptions) {
+		o.fallback = builder
+	}
+}
+
+// consistent hashing with bounded loads：每个节点正在处理的请求数不超过平均值（按权重）的 c 倍，
+// 超出时沿着环顺时针找下一个节点，避免热点 key 压垮一个节点；c 通常取 1.25，0 表示不限制，小于 1 时按 1 处理
+func WithBoundedLoad(c float64) ConsistentHashOption {
+	return func(o *consistentHashOptions) {
+		if c > 0 && c < 1 {
+			c = 1
+		}
+		o.loadFactor = c
+	}
+}
+
+// hash key 从 ctx.Value(consistentHashKey) 或者 outgoing metadata 中的 consistentHashKey 读取
+//
+//	ctx = metadata.AppendToOutgoingContext(ctx, balancer_policy.DefaultConsistentHashKey, userID)
+func InitConsistentHashBuilder(consistentHashKey string, opts ...ConsistentHashOption) {
+	balancer.Register(newConsistentHashBuilder(consistentHashKey, opts...))
 }
 
 // newConsistanceHashBuilder creates a new ConsistanceHash balancer builder.
-func newConsistentHashBuilder(consistentHashKey string) balancer.Builder {
-	return base.NewBalancerBuilder(ConsistentHash, &consistentHashPickerBuilder{consistentHashKey}, base.Config{HealthCheck: true})
+func newConsistentHashBuilder(consistentHashKey string, opts ...ConsistentHashOption) balancer.Builder {
+	options := consistentHashOptions{
+		fallback: &weightedRoundRobinPickerBuilder{},
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return base.NewBalancerBuilder(ConsistentHash, &consistentHashPickerBuilder{consistentHashKey: consistentHashKey, options: options}, base.Config{HealthCheck: true})
 }
 
 type consistentHashPickerBuilder struct {
 	consistentHashKey string
+	options           consistentHashOptions
+
+	// 每个 SubConn 正在处理的请求数，SubConn 状态变化时 picker 会重建，计数要跟着保留
+	mu       sync.Mutex
+	inflight map[balancer.SubConn]*int64
 }
 
 func (b *consistentHashPickerBuilder) Build(buildInfo base.PickerBuildInfo) balancer.Picker {
@@ -34,38 +78,130 @@ func (b *consistentHashPickerBuilder) Build(buildInfo base.PickerBuildInfo) bala
 	}
 
 	picker := &consistentHashPicker{
-		subConns:          make(map[string]balancer.SubConn),
+		subConns:          make(map[string]*hashNode),
 		hash:              NewKetama(10, nil),
 		consistentHashKey: b.consistentHashKey,
+		fallback:          b.options.fallback.Build(buildInfo),
+		loadFactor:        b.options.loadFactor,
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.inflight == nil {
+		b.inflight = make(map[balancer.SubConn]*int64)
+	}
+	// 同一个 builder 被多个 ClientConn 共用，只清理没有请求的计数，其他 ClientConn 的计数删掉后也会从 0 重建
+	for sc, inflight := range b.inflight {
+		if _, ok := buildInfo.ReadySCs[sc]; !ok && atomic.LoadInt64(inflight) == 0 {
+			delete(b.inflight, sc)
+		}
 	}
 
 	for sc, conInfo := range buildInfo.ReadySCs {
+		inflight := b.inflight[sc]
+		if inflight == nil {
+			inflight = new(int64)
+			b.inflight[sc] = inflight
+		}
 		weight := common.GetWeight(conInfo.Address)
+		node := &hashNode{subConn: sc, weight: weight, inflight: inflight}
 		for i := 0; i < weight; i++ {
-			node := wrapAddr(conInfo.Address.Addr, i)
-			picker.hash.Add(node)
-			picker.subConns[node] = sc
+			name := wrapAddr(conInfo.Address.Addr, i)
+			picker.hash.Add(name)
+			picker.subConns[name] = node
+		}
+		if weight > 0 {
+			picker.nodes = append(picker.nodes, node)
+			picker.totalWeight += weight
 		}
 	}
 	return picker
 }
 
+type hashNode struct {
+	subConn  balancer.SubConn
+	weight   int
+	inflight *int64
+}
+
 type consistentHashPicker struct {
-	subConns          map[string]balancer.SubConn
+	subConns          map[string]*hashNode
 	hash              *Ketama
 	consistentHashKey string
+	fallback          balancer.Picker
+
+	nodes       []*hashNode
+	loadFactor  float64
+	totalWeight int
 }
 
 func (p *consistentHashPicker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
-	var ret balancer.PickResult
-	key, ok := info.Ctx.Value(p.consistentHashKey).(string)
-	if ok {
-		targetAddr, ok := p.hash.Get(key)
-		if ok {
-			ret.SubConn = p.subConns[targetAddr]
+	key, ok := p.hashKey(info.Ctx)
+	if !ok {
+		return p.fallback.Pick(info)
+	}
+	node := p.lookup(key)
+	if node == nil {
+		return p.fallback.Pick(info)
+	}
+
+	inflight := node.inflight
+	atomic.AddInt64(inflight, 1)
+	return balancer.PickResult{
+		SubConn: node.subConn,
+		Done: func(balancer.DoneInfo) {
+			atomic.AddInt64(inflight, -1)
+		},
+	}, nil
+}
+
+func (p *consistentHashPicker) hashKey(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	if key, ok := ctx.Value(p.consistentHashKey).(string); ok {
+		return key, true
+	}
+	if md, ok := metadata.FromOutgoingContext(ctx); ok {
+		if values := md.Get(p.consistentHashKey); len(values) > 0 {
+			return values[0], true
+		}
+	}
+	return "", false
+}
+
+// 环上 key 对应的节点，开启 bounded load 时跳过已经满载的节点；都满载时返回第一个
+func (p *consistentHashPicker) lookup(key string) *hashNode {
+	var first, picked *hashNode
+	total := 1.0
+	if p.loadFactor > 0 {
+		for _, node := range p.nodes {
+			total += float64(atomic.LoadInt64(node.inflight))
+		}
+	}
+	p.hash.Walk(key, func(name string) bool {
+		node := p.subConns[name]
+		if node == nil {
+			return true
+		}
+		if first == nil {
+			first = node
+		}
+		if p.loadFactor <= 0 {
+			picked = node
+			return false
+		}
+		limit := math.Ceil(p.loadFactor * total * float64(node.weight) / float64(p.totalWeight))
+		if float64(atomic.LoadInt64(node.inflight)+1) <= limit {
+			picked = node
+			return false
 		}
+		return true
+	})
+	if picked == nil {
+		picked = first
 	}
-	return ret, nil
+	return picked
 }
 
 func wrapAddr(addr string, idx int) string {
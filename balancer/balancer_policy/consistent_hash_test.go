@@ -0,0 +1,105 @@
+package balancer_policy
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/metadata"
+)
+
+func hashPick(t *testing.T, picker balancer.Picker, ctx context.Context) (string, func(balancer.DoneInfo)) {
+	ret, err := picker.Pick(balancer.PickInfo{Ctx: ctx})
+	if err != nil || ret.SubConn == nil {
+		t.Fatalf("pick: %+v %v", ret, err)
+	}
+	return ret.SubConn.(*testSubConn).addr, ret.Done
+}
+
+func TestConsistentHashKey(t *testing.T) {
+	b := &consistentHashPickerBuilder{consistentHashKey: DefaultConsistentHashKey, options: consistentHashOptions{fallback: &weightedRoundRobinPickerBuilder{}}}
+	picker := b.Build(testBuildInfo("a:1", "b:1", "c:1"))
+
+	// ctx value 和 metadata 中相同的 key 落到同一个节点
+	for _, key := range []string{"u1", "u2", "u3", "u4"} {
+		byValue, _ := hashPick(t, picker, context.WithValue(context.Background(), DefaultConsistentHashKey, key))
+		byMD, _ := hashPick(t, picker, metadata.AppendToOutgoingContext(context.Background(), DefaultConsistentHashKey, key))
+		again, _ := hashPick(t, picker, context.WithValue(context.Background(), DefaultConsistentHashKey, key))
+		if byValue != byMD || byValue != again {
+			t.Fatalf("key %s: %s %s %s", key, byValue, byMD, again)
+		}
+	}
+
+	// 没有 key 时使用 fallback
+	counts := map[string]int{}
+	for i := 0; i < 30; i++ {
+		addr, _ := hashPick(t, picker, context.Background())
+		counts[addr]++
+	}
+	if counts["a"] != 10 || counts["b"] != 10 || counts["c"] != 10 {
+		t.Fatalf("fallback: %v", counts)
+	}
+}
+
+func TestConsistentHashBoundedLoad(t *testing.T) {
+	b := &consistentHashPickerBuilder{consistentHashKey: DefaultConsistentHashKey, options: consistentHashOptions{fallback: &weightedRoundRobinPickerBuilder{}}}
+	WithBoundedLoad(1.25)(&b.options)
+	picker := b.Build(testBuildInfo("a:1", "b:1", "c:1", "d:1"))
+	ctx := context.WithValue(context.Background(), DefaultConsistentHashKey, "hot")
+
+	home, done := hashPick(t, picker, ctx)
+	done(balancer.DoneInfo{})
+	// 请求结束后 in-flight 归零，仍然落到原来的节点
+	if addr, done := hashPick(t, picker, ctx); addr != home {
+		t.Fatalf("home changed: %s %s", home, addr)
+	} else {
+		done(balancer.DoneInfo{})
+	}
+
+	// 热点 key 的请求一直不结束，超过上限后分散到其他节点
+	counts := map[string]int{}
+	for i := 0; i < 100; i++ {
+		addr, _ := hashPick(t, picker, ctx)
+		counts[addr]++
+	}
+	if len(counts) != 4 || counts[home] > 32 {
+		t.Fatalf("bounded load: home %s %v", home, counts)
+	}
+	for addr, n := range counts {
+		if n > 32 {
+			t.Fatalf("%s overloaded: %v", addr, counts)
+		}
+	}
+}
+
+func TestConsistentHashBoundedLoadAcrossRebuild(t *testing.T) {
+	b := &consistentHashPickerBuilder{consistentHashKey: DefaultConsistentHashKey, options: consistentHashOptions{fallback: &weightedRoundRobinPickerBuilder{}}}
+	WithBoundedLoad(1.25)(&b.options)
+	info := testBuildInfo("a:1", "b:1", "c:1", "d:1")
+	ctx := context.WithValue(context.Background(), DefaultConsistentHashKey, "hot")
+
+	picker := b.Build(info)
+	home, done := hashPick(t, picker, ctx)
+	dones := []func(balancer.DoneInfo){done}
+	for i := 0; i < 99; i++ {
+		_, done := hashPick(t, picker, ctx)
+		dones = append(dones, done)
+	}
+
+	// SubConn 状态变化导致 picker 重建，正在处理的请求仍然计入负载，热点节点已经满载
+	picker = b.Build(info)
+	if addr, done := hashPick(t, picker, ctx); addr == home {
+		t.Fatalf("home %s picked again after rebuild", home)
+	} else {
+		dones = append(dones, done)
+	}
+
+	for _, done := range dones {
+		done(balancer.DoneInfo{})
+	}
+	for sc, inflight := range b.inflight {
+		if *inflight != 0 {
+			t.Fatalf("%s inflight %d", sc.(*testSubConn).addr, *inflight)
+		}
+	}
+}
@@ -131,3 +131,21 @@ func (h *Ketama) Get(key string) (string, bool) {
 	str, ok := h.hashMap[h.keys[idx]]
 	return str, ok
 }
+
+// 从 key 的位置开始顺时针遍历环上的节点，同一个节点可能回调多次，fn 返回 false 时停止
+// fn 在持有锁的情况下调用，不能再调用 Ketama 的方法
+func (h *Ketama) Walk(key string, fn func(node string) bool) {
+	hash := int(h.hash([]byte(key)))
+
+	h.Lock()
+	defer h.Unlock()
+
+	idx := sort.Search(len(h.keys), func(i int) bool {
+		return h.keys[i] >= hash
+	})
+	for i := 0; i < len(h.keys); i++ {
+		if !fn(h.hashMap[h.keys[(idx+i)%len(h.keys)]]) {
+			return
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package balancer_policy
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/grpclog"
+
+	"github.com/DoOR-Team/goutils/balancer/common"
+)
+
+const (
+	WeightedRoundRobin = "weighted_round_robin_x"
+	WeightedRandom     = "weighted_random_x"
+)
+
+func newWeightedRoundRobinBuilder() balancer.Builder {
+	return base.NewBalancerBuilder(WeightedRoundRobin, &weightedRoundRobinPickerBuilder{}, base.Config{HealthCheck: true})
+}
+
+func newWeightedRandomBuilder() balancer.Builder {
+	return base.NewBalancerBuilder(WeightedRandom, &weightedRandomPickerBuilder{}, base.Config{HealthCheck: true})
+}
+
+func init() {
+	balancer.Register(newWeightedRoundRobinBuilder())
+	balancer.Register(newWeightedRandomBuilder())
+}
+
+type weightedNode struct {
+	subConn       balancer.SubConn
+	addr          string
+	weight        int
+	currentWeight int
+}
+
+// 按地址排序，weight 为 0 的节点不分配流量；全部为 0 时按相同权重处理，避免没有可用节点
+func weightedNodes(buildInfo base.PickerBuildInfo) ([]*weightedNode, int) {
+	var nodes []*weightedNode
+	for subConn, subConnInfo := range buildInfo.ReadySCs {
+		weight := common.GetWeight(subConnInfo.Address)
+		if weight < 0 {
+			weight = 0
+		}
+		nodes = append(nodes, &weightedNode{subConn: subConn, addr: subConnInfo.Address.Addr, weight: weight})
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].addr < nodes[j].addr
+	})
+
+	var active []*weightedNode
+	total := 0
+	for _, node := range nodes {
+		if node.weight > 0 {
+			active = append(active, node)
+			total += node.weight
+		}
+	}
+	if total == 0 {
+		for _, node := range nodes {
+			node.weight = 1
+		}
+		return nodes, len(nodes)
+	}
+	return active, total
+}
+
+type weightedRoundRobinPickerBuilder struct{}
+
+func (*weightedRoundRobinPickerBuilder) Build(buildInfo base.PickerBuildInfo) balancer.Picker {
+	grpclog.Infof("weightedRoundRobinPicker: newPicker called with buildInfo: %v", buildInfo)
+	if len(buildInfo.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+
+	nodes, total := weightedNodes(buildInfo)
+	picker := &weightedRoundRobinPicker{nodes: nodes, total: total}
+	// 各个客户端从不同的位置开始，避免同时把第一批请求打到权重最大的节点上
+	// 随机初始化 currentWeight 而不是空转若干次，权重很大时重建 picker 也只需要 O(n)，长期的流量比例不变
+	for _, node := range nodes {
+		node.currentWeight = rand.Intn(total)
+	}
+	return picker
+}
+
+// nginx 的平滑加权轮询：每次所有节点的 currentWeight 加上自身权重，选出最大的节点后减去总权重
+// 权重为 5、1、1 时选择顺序为 a a b a c a a，而不是 a a a a a b c
+type weightedRoundRobinPicker struct {
+	nodes []*weightedNode
+	total int
+	mu    sync.Mutex
+}
+
+func (p *weightedRoundRobinPicker) next() *weightedNode {
+	var best *weightedNode
+	for _, node := range p.nodes {
+		node.currentWeight += node.weight
+		if best == nil || node.currentWeight > best.currentWeight {
+			best = node
+		}
+	}
+	best.currentWeight -= p.total
+	return best
+}
+
+func (p *weightedRoundRobinPicker) Pick(balancer.PickInfo) (balancer.PickResult, error) {
+	ret := balancer.PickResult{}
+	p.mu.Lock()
+	ret.SubConn = p.next().subConn
+	p.mu.Unlock()
+	return ret, nil
+}
+
+type weightedRandomPickerBuilder struct{}
+
+func (*weightedRandomPickerBuilder) Build(buildInfo base.PickerBuildInfo) balancer.Picker {
+	grpclog.Infof("weightedRandomPicker: newPicker called with buildInfo: %v", buildInfo)
+	if len(buildInfo.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+
+	nodes, total := weightedNodes(buildInfo)
+	picker := &weightedRandomPicker{
+		subConns: make([]balancer.SubConn, len(nodes)),
+		bounds:   make([]int, len(nodes)),
+		total:    total,
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	sum := 0
+	for i, node := range nodes {
+		sum += node.weight
+		picker.subConns[i] = node.subConn
+		picker.bounds[i] = sum
+	}
+	return picker
+}
+
+// 按权重随机，bounds 为权重的前缀和，二分查找落在哪个节点上
+type weightedRandomPicker struct {
+	subConns []balancer.SubConn
+	bounds   []int
+	total    int
+	mu       sync.Mutex
+	rand     *rand.Rand
+}
+
+func (p *weightedRandomPicker) Pick(balancer.PickInfo) (balancer.PickResult, error) {
+	ret := balancer.PickResult{}
+	p.mu.Lock()
+	n := p.rand.Intn(p.total)
+	p.mu.Unlock()
+	i := sort.Search(len(p.bounds), func(i int) bool {
+		return p.bounds[i] > n
+	})
+	ret.SubConn = p.subConns[i]
+	return ret, nil
+}
@@ -0,0 +1,131 @@
+package balancer_policy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/resolver"
+
+	"github.com/DoOR-Team/goutils/balancer/common"
+)
+
+type testSubConn struct {
+	addr string
+}
+
+func (*testSubConn) UpdateAddresses([]resolver.Address) {}
+func (*testSubConn) Connect()                           {}
+
+// weights 为 地址:权重
+func testBuildInfo(weights ...string) base.PickerBuildInfo {
+	info := base.PickerBuildInfo{ReadySCs: map[balancer.SubConn]base.SubConnInfo{}}
+	for _, w := range weights {
+		parts := strings.Split(w, ":")
+		md := metadata.Pairs(common.WeightKey, parts[1])
+		info.ReadySCs[&testSubConn{addr: parts[0]}] = base.SubConnInfo{
+			Address: resolver.Address{Addr: parts[0], Metadata: &md},
+		}
+	}
+	return info
+}
+
+func pickAddrs(t *testing.T, picker balancer.Picker, n int) []string {
+	var addrs []string
+	for i := 0; i < n; i++ {
+		ret, err := picker.Pick(balancer.PickInfo{Ctx: context.Background()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		addrs = append(addrs, ret.SubConn.(*testSubConn).addr)
+	}
+	return addrs
+}
+
+func countAddrs(addrs []string) map[string]int {
+	counts := map[string]int{}
+	for _, addr := range addrs {
+		counts[addr]++
+	}
+	return counts
+}
+
+// 去掉随机的起始位置，从一个周期的开头比较
+func resetWeights(picker *weightedRoundRobinPicker) *weightedRoundRobinPicker {
+	sort.Slice(picker.nodes, func(i, j int) bool {
+		return picker.nodes[i].subConn.(*testSubConn).addr < picker.nodes[j].subConn.(*testSubConn).addr
+	})
+	for _, node := range picker.nodes {
+		node.currentWeight = 0
+	}
+	return picker
+}
+
+func TestWeightedRoundRobin(t *testing.T) {
+	picker := resetWeights((&weightedRoundRobinPickerBuilder{}).Build(testBuildInfo("a:5", "b:1", "c:1")).(*weightedRoundRobinPicker))
+	if got := strings.Join(pickAddrs(t, picker, 7), " "); got != "a a b a c a a" {
+		t.Fatalf("smooth order: %s", got)
+	}
+
+	// 权重为 0 的节点不分配流量
+	picker = resetWeights((&weightedRoundRobinPickerBuilder{}).Build(testBuildInfo("a:3", "b:0", "c:1")).(*weightedRoundRobinPicker))
+	counts := countAddrs(pickAddrs(t, picker, 400))
+	if counts["a"] != 300 || counts["b"] != 0 || counts["c"] != 100 {
+		t.Fatalf("counts: %v", counts)
+	}
+
+	// 全部为 0 时平均分配
+	picker = resetWeights((&weightedRoundRobinPickerBuilder{}).Build(testBuildInfo("a:0", "b:0")).(*weightedRoundRobinPicker))
+	counts = countAddrs(pickAddrs(t, picker, 10))
+	if counts["a"] != 5 || counts["b"] != 5 {
+		t.Fatalf("all zero: %v", counts)
+	}
+}
+
+func TestWeightedRoundRobinRandomStart(t *testing.T) {
+	firsts := map[string]int{}
+	for i := 0; i < 100; i++ {
+		picker := (&weightedRoundRobinPickerBuilder{}).Build(testBuildInfo("a:5", "b:1", "c:1"))
+		addrs := pickAddrs(t, picker, 700)
+		firsts[addrs[0]]++
+		// 随机的起始位置不影响流量比例
+		counts := countAddrs(addrs)
+		if counts["a"] < 498 || counts["a"] > 502 || counts["b"] < 98 || counts["b"] > 102 {
+			t.Fatalf("counts: %v", counts)
+		}
+	}
+	if len(firsts) < 2 {
+		t.Fatalf("first picks: %v", firsts)
+	}
+
+	// 权重很大时重建 picker 不需要按总权重空转
+	weights := []string{}
+	for i := 0; i < 100; i++ {
+		weights = append(weights, fmt.Sprintf("n%d:65535", i))
+	}
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		(&weightedRoundRobinPickerBuilder{}).Build(testBuildInfo(weights...))
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("build too slow: %v", d)
+	}
+}
+
+func TestWeightedRandom(t *testing.T) {
+	picker := (&weightedRandomPickerBuilder{}).Build(testBuildInfo("a:9", "b:0", "c:1"))
+	counts := countAddrs(pickAddrs(t, picker, 10000))
+	if counts["b"] != 0 || counts["a"] < 8500 || counts["c"] < 500 {
+		t.Fatalf("counts: %v", counts)
+	}
+
+	if _, err := (&weightedRandomPickerBuilder{}).Build(base.PickerBuildInfo{}).Pick(balancer.PickInfo{}); err != balancer.ErrNoSubConnAvailable {
+		t.Fatalf("empty: %v", err)
+	}
+}
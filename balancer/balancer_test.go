@@ -1,7 +1,116 @@
 package balancer
 
-import "testing"
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/DoOR-Team/goutils/balancer/balancer_policy"
+	"github.com/DoOR-Team/goutils/balancer/registry/k8s"
+)
 
 func Test_NewRPCClient(t *testing.T) {
-	_ = NewRPCClient("laike.daily.svc.cluster.local:9988")
+	_, err := NewRPCClient("laike.daily.svc.cluster.local:9988")
+	if k8s.InitK8sClient() == nil {
+		// 集群外并且没有 kube config 时 k8s resolver 无法注册，返回错误而不是结束进程
+		if err == nil {
+			t.Fatal("expect error without k8s config")
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolveTarget(t *testing.T) {
+	cases := []struct {
+		address, resolver    string
+		wantAddr, wantScheme string
+	}{
+		{"127.0.0.1:9001", "", "127.0.0.1:9001", ""},
+		{"static:///127.0.0.1:9001,127.0.0.1:9002", "", "static:///127.0.0.1:9001,127.0.0.1:9002", "static"},
+		{"127.0.0.1:9001,127.0.0.1:9002", "static", "static:///127.0.0.1:9001,127.0.0.1:9002", "static"},
+		{"dnsx:///svc.local:9001", "static", "dnsx:///svc.local:9001", "dnsx"},
+		{"svc.local:9001", "dnsx", "dnsx:///svc.local:9001", "dnsx"},
+	}
+	for _, c := range cases {
+		addr, scheme, err := resolveTarget(c.address, c.resolver)
+		if err != nil || addr != c.wantAddr || scheme != c.wantScheme {
+			t.Errorf("resolveTarget(%q, %q) = %q, %q, %v", c.address, c.resolver, addr, scheme, err)
+		}
+	}
+	if _, _, err := resolveTarget("127.0.0.1:9001", "not-exist"); err == nil {
+		t.Error("expect unknown resolver error")
+	}
+}
+
+func startHealthServer(t *testing.T) (string, func()) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	go s.Serve(lis)
+	return lis.Addr().String(), s.Stop
+}
+
+func TestNewRPCClientOptions(t *testing.T) {
+	addr1, stop1 := startHealthServer(t)
+	defer stop1()
+	addr2, stop2 := startHealthServer(t)
+	defer stop2()
+
+	// 多个拦截器都要执行，默认超时生效
+	var calls []string
+	var deadline time.Time
+	first := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		calls = append(calls, "first")
+		deadline, _ = ctx.Deadline()
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	second := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		calls = append(calls, "second")
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+
+	conn, err := NewRPCClient(addr1+","+addr2,
+		WithResolver("static"),
+		WithBalancerPolicy(balancer_policy.WeightedRoundRobin),
+		WithTimeout(time.Second),
+		WithDialTimeout(5*time.Second),
+		WithMaxMsgSize(16<<20),
+		WithUnaryInterceptor(first),
+		WithUnaryInterceptor(second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	resp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil || resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("check: %v %v", resp, err)
+	}
+	if len(calls) != 2 || calls[0] != "first" || calls[1] != "second" {
+		t.Fatalf("interceptors: %v", calls)
+	}
+	if d := deadline.Sub(start); d < time.Second || d > 2*time.Second {
+		t.Fatalf("deadline: %v", d)
+	}
+
+	// 连接失败时返回错误而不是退出进程
+	lis, _ := net.Listen("tcp", "127.0.0.1:0")
+	closed := lis.Addr().String()
+	lis.Close()
+	if _, err := NewRPCClient(closed, WithDialTimeout(200*time.Millisecond)); err == nil {
+		t.Fatal("expect dial error")
+	}
 }
@@ -0,0 +1,164 @@
+package balancer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/resolver"
+
+	"github.com/DoOR-Team/goutils/balancer/registry/k8s"
+	"github.com/DoOR-Team/goutils/log"
+
+	// 注册 static、dnsx resolver
+	_ "github.com/DoOR-Team/goutils/balancer/registry/dns"
+	_ "github.com/DoOR-Team/goutils/balancer/registry/static"
+)
+
+const k8sScheme = "k8s"
+
+type clientOptions struct {
+	resolver string
+	policy   string
+
+	tls         *tls.Config
+	keepalive   *keepalive.ClientParameters
+	timeout     time.Duration
+	dialTimeout time.Duration
+	maxMsgSize  int
+	unary       []grpc.UnaryClientInterceptor
+	stream      []grpc.StreamClientInterceptor
+	userInfoKey string
+	userInfo    interface{}
+	dialOptions []grpc.DialOption
+}
+
+type Option func(*clientOptions)
+
+// 指定 resolver 的 scheme，如 k8s、static、dnsx，不再根据地址猜测；地址中已经带有 scheme 时以地址为准
+//
+//	balancer.NewRPCClient("127.0.0.1:9001,127.0.0.1:9002", balancer.WithResolver("static"))
+func WithResolver(scheme string) Option {
+	return func(o *clientOptions) {
+		o.resolver = scheme
+	}
+}
+
+// 指定负载均衡策略，如 balancer_policy.WeightedRoundRobin，使用 resolver 时默认为 balancer_policy.RoundRobin
+func WithBalancerPolicy(policy string) Option {
+	return func(o *clientOptions) {
+		o.policy = policy
+	}
+}
+
+// 返回 grpc Dial 的地址以及使用的 resolver，不使用 resolver 时为空
+func resolveTarget(address string, scheme string) (string, string, error) {
+	endpoint := address
+	if i := strings.Index(address, "://"); i >= 0 {
+		scheme = address[:i]
+		endpoint = strings.TrimPrefix(address[i+3:], "/")
+	} else if scheme == "" {
+		// 没有指定时按照 svc.env:port 猜测是否是 k8s 服务
+		attrs := strings.Split(address, ".")
+		if len(attrs) >= 2 && (attrs[1] == "daily" || attrs[1] == "production") {
+			scheme = k8sScheme
+		}
+	}
+	if scheme == "" {
+		return address, "", nil
+	}
+
+	if scheme == k8sScheme {
+		attrs := strings.Split(endpoint, ".")
+		i := strings.Index(endpoint, ":")
+		if len(attrs) < 2 || i < 0 {
+			return "", "", fmt.Errorf("k8s address should be svc.env:port: %s", endpoint)
+		}
+		env := strings.Split(attrs[1], ":")[0]
+		k8s.RegisterResolver(k8sScheme, endpoint[i:], env, attrs[0], time.Second*5)
+	}
+	// 没有注册的 scheme 会被 grpc 当成普通地址，这里提前报错
+	if resolver.Get(scheme) == nil {
+		return "", "", fmt.Errorf("resolver %q is not registered", scheme)
+	}
+	address = scheme + ":///" + endpoint
+	log.Info("address changing to", address)
+	return address, scheme, nil
+}
+
+// 使用 TLS 连接，默认不加密
+func WithTLS(config *tls.Config) Option {
+	return func(o *clientOptions) {
+		o.tls = config
+	}
+}
+
+func WithKeepalive(params keepalive.ClientParameters) Option {
+	return func(o *clientOptions) {
+		o.keepalive = &params
+	}
+}
+
+// unary 调用默认的超时时间，ctx 已经有 deadline 时不生效
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *clientOptions) {
+		o.timeout = timeout
+	}
+}
+
+// 阻塞等待连接建立，超时后 NewRPCClient 返回错误；默认不等待
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(o *clientOptions) {
+		o.dialTimeout = timeout
+	}
+}
+
+// 发送和接收的消息大小上限，grpc 默认接收 4MB
+func WithMaxMsgSize(size int) Option {
+	return func(o *clientOptions) {
+		o.maxMsgSize = size
+	}
+}
+
+// 在内置的 tracing、用户信息拦截器之后依次执行
+func WithUnaryInterceptor(interceptors ...grpc.UnaryClientInterceptor) Option {
+	return func(o *clientOptions) {
+		o.unary = append(o.unary, interceptors...)
+	}
+}
+
+func WithStreamInterceptor(interceptors ...grpc.StreamClientInterceptor) Option {
+	return func(o *clientOptions) {
+		o.stream = append(o.stream, interceptors...)
+	}
+}
+
+// 把 ctx.Value(userInfoKey) 通过 metadata 传给服务端
+func WithUserInfo(userInfoKey string, userInfo interface{}) Option {
+	return func(o *clientOptions) {
+		o.userInfoKey = userInfoKey
+		o.userInfo = userInfo
+	}
+}
+
+// 其他 grpc 的 DialOption，放在最后
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(o *clientOptions) {
+		o.dialOptions = append(o.dialOptions, opts...)
+	}
+}
+
+func timeoutInterceptor(timeout time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if _, ok := ctx.Deadline(); !ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
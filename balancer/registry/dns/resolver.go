@@ -0,0 +1,208 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/resolver"
+
+	"github.com/DoOR-Team/goutils/balancer/common"
+	"github.com/DoOR-Team/goutils/log"
+)
+
+// 定期刷新的 DNS resolver，支持 A 记录和 SRV 记录：
+//
+//	dnsx:///my-service.local:9001              A/AAAA 记录，端口取自地址
+//	dnsx:///_grpc._tcp.my-service.example.com  SRV 记录，端口、权重取自记录，只使用优先级最高的一组
+//
+// grpc 自带的 dns resolver 最快 30s 才刷新一次并且不支持 SRV
+const Scheme = "dnsx"
+
+const (
+	DefaultRefreshInterval = 30 * time.Second
+	defaultPort            = "443"
+	// ResolveNow 触发的解析之间至少间隔这么久，避免连接失败时频繁查询
+	minResolveInterval = time.Second
+	lookupTimeout      = 10 * time.Second
+)
+
+func init() {
+	resolver.Register(NewBuilder(Scheme, DefaultRefreshInterval))
+}
+
+// 用其他 scheme 和刷新间隔注册
+func RegisterResolver(scheme string, refresh time.Duration) {
+	resolver.Register(NewBuilder(scheme, refresh))
+}
+
+// 便于测试时替换，net.DefaultResolver 实现了这个接口
+type lookuper interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+func NewBuilder(scheme string, refresh time.Duration) resolver.Builder {
+	if refresh <= 0 {
+		refresh = DefaultRefreshInterval
+	}
+	return &dnsBuilder{scheme: scheme, refresh: refresh, lookup: net.DefaultResolver}
+}
+
+type dnsBuilder struct {
+	scheme  string
+	refresh time.Duration
+	lookup  lookuper
+}
+
+func (b *dnsBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	if target.Endpoint == "" {
+		return nil, fmt.Errorf("dns resolver: empty target")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &dnsResolver{
+		target:     target.Endpoint,
+		refresh:    b.refresh,
+		lookup:     b.lookup,
+		cc:         cc,
+		ctx:        ctx,
+		cancel:     cancel,
+		resolveNow: make(chan struct{}, 1),
+	}
+	if !r.isSRV() {
+		host, port, err := net.SplitHostPort(target.Endpoint)
+		if err != nil {
+			host, port = target.Endpoint, defaultPort
+		}
+		r.host, r.port = host, port
+	}
+	r.wg.Add(1)
+	go r.watch()
+	return r, nil
+}
+
+func (b *dnsBuilder) Scheme() string {
+	return b.scheme
+}
+
+type dnsResolver struct {
+	target  string
+	host    string
+	port    string
+	refresh time.Duration
+	lookup  lookuper
+
+	cc         resolver.ClientConn
+	ctx        context.Context
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+	resolveNow chan struct{}
+}
+
+func (r *dnsResolver) isSRV() bool {
+	return strings.HasPrefix(r.target, "_")
+}
+
+func (r *dnsResolver) watch() {
+	defer r.wg.Done()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-timer.C:
+		case <-r.resolveNow:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		}
+
+		addrs, err := r.resolve()
+		if err != nil {
+			log.Warnf("[dns resolver] 解析 %s 失败: %v", r.target, err)
+			r.cc.ReportError(err)
+		} else if len(addrs) == 0 {
+			// 和 k8s watcher 一样，没有地址时保留之前的结果
+			log.Warnf("[dns resolver] %s 没有解析到地址", r.target)
+		} else {
+			r.cc.UpdateState(resolver.State{Addresses: addrs})
+		}
+		timer.Reset(r.refresh)
+
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-time.After(minResolveInterval):
+		}
+	}
+}
+
+func (r *dnsResolver) resolve() ([]resolver.Address, error) {
+	ctx, cancel := context.WithTimeout(r.ctx, lookupTimeout)
+	defer cancel()
+
+	if !r.isSRV() {
+		hosts, err := r.lookup.LookupHost(ctx, r.host)
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(hosts)
+		addrs := make([]resolver.Address, 0, len(hosts))
+		for _, host := range hosts {
+			addrs = append(addrs, resolver.Address{Addr: net.JoinHostPort(host, r.port)})
+		}
+		return addrs, nil
+	}
+
+	_, srvs, err := r.lookup.LookupSRV(ctx, "", "", r.target)
+	if err != nil {
+		return nil, err
+	}
+	var addrs []resolver.Address
+	for _, srv := range srvs {
+		// LookupSRV 已经按优先级排好序，只使用优先级最高的一组
+		if srv.Priority != srvs[0].Priority {
+			break
+		}
+		hosts, err := r.lookup.LookupHost(ctx, srv.Target)
+		if err != nil {
+			log.Warnf("[dns resolver] 解析 %s 失败: %v", srv.Target, err)
+			continue
+		}
+		// SRV 中权重 0 表示不区分权重
+		weight := int(srv.Weight)
+		if weight == 0 {
+			weight = 1
+		}
+		md := metadata.Pairs(common.WeightKey, strconv.Itoa(weight))
+		for _, host := range hosts {
+			addrs = append(addrs, resolver.Address{
+				Addr:     net.JoinHostPort(host, strconv.Itoa(int(srv.Port))),
+				Metadata: &md,
+			})
+		}
+	}
+	return addrs, nil
+}
+
+func (r *dnsResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.resolveNow <- struct{}{}:
+	default:
+	}
+}
+
+func (r *dnsResolver) Close() {
+	r.cancel()
+	r.wg.Wait()
+}
@@ -0,0 +1,123 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/serviceconfig"
+
+	"github.com/DoOR-Team/goutils/balancer/common"
+)
+
+type fakeLookup struct {
+	mu    sync.Mutex
+	hosts map[string][]string
+	srvs  map[string][]*net.SRV
+}
+
+func (f *fakeLookup) set(host string, ips ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.hosts[host] = ips
+}
+
+func (f *fakeLookup) LookupHost(ctx context.Context, host string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ips, ok := f.hosts[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return ips, nil
+}
+
+func (f *fakeLookup) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return name, f.srvs[name], nil
+}
+
+type fakeClientConn struct {
+	resolver.ClientConn
+	states chan resolver.State
+}
+
+func (c *fakeClientConn) UpdateState(s resolver.State) {
+	c.states <- s
+}
+
+func (c *fakeClientConn) ReportError(error) {}
+
+func (c *fakeClientConn) ParseServiceConfig(string) *serviceconfig.ParseResult {
+	return nil
+}
+
+func nextAddrs(t *testing.T, cc *fakeClientConn) []resolver.Address {
+	select {
+	case s := <-cc.states:
+		return s.Addresses
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for state")
+	}
+	return nil
+}
+
+func TestDNSResolverA(t *testing.T) {
+	lookup := &fakeLookup{hosts: map[string][]string{"svc.local": {"10.0.0.2", "10.0.0.1"}}}
+	b := &dnsBuilder{scheme: Scheme, refresh: 50 * time.Millisecond, lookup: lookup}
+	cc := &fakeClientConn{states: make(chan resolver.State, 10)}
+	r, err := b.Build(resolver.Target{Scheme: Scheme, Endpoint: "svc.local:9001"}, cc, resolver.BuildOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	addrs := nextAddrs(t, cc)
+	if len(addrs) != 2 || addrs[0].Addr != "10.0.0.1:9001" || addrs[1].Addr != "10.0.0.2:9001" {
+		t.Fatalf("addrs: %v", addrs)
+	}
+
+	// 定期刷新
+	lookup.set("svc.local", "10.0.0.3")
+	for {
+		addrs = nextAddrs(t, cc)
+		if len(addrs) == 1 && addrs[0].Addr == "10.0.0.3:9001" {
+			break
+		}
+	}
+}
+
+func TestDNSResolverSRV(t *testing.T) {
+	lookup := &fakeLookup{
+		hosts: map[string][]string{"a.local.": {"10.0.0.1"}, "b.local.": {"10.0.0.2"}, "backup.local.": {"10.0.0.9"}},
+		srvs: map[string][]*net.SRV{"_grpc._tcp.svc.local": {
+			{Target: "a.local.", Port: 9001, Priority: 1, Weight: 3},
+			{Target: "b.local.", Port: 9002, Priority: 1, Weight: 0},
+			{Target: "backup.local.", Port: 9003, Priority: 2, Weight: 1},
+		}},
+	}
+	b := &dnsBuilder{scheme: Scheme, refresh: time.Hour, lookup: lookup}
+	cc := &fakeClientConn{states: make(chan resolver.State, 10)}
+	r, err := b.Build(resolver.Target{Scheme: Scheme, Endpoint: "_grpc._tcp.svc.local"}, cc, resolver.BuildOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	addrs := nextAddrs(t, cc)
+	if len(addrs) != 2 || addrs[0].Addr != "10.0.0.1:9001" || addrs[1].Addr != "10.0.0.2:9002" {
+		t.Fatalf("addrs: %v", addrs)
+	}
+	if common.GetWeight(addrs[0]) != 3 || common.GetWeight(addrs[1]) != 1 {
+		t.Fatalf("weights: %d %d", common.GetWeight(addrs[0]), common.GetWeight(addrs[1]))
+	}
+
+	// ResolveNow 立即重新解析
+	r.ResolveNow(resolver.ResolveNowOptions{})
+	nextAddrs(t, cc)
+}
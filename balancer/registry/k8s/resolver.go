@@ -97,6 +97,7 @@ func RegisterResolver(schema, port string, namespace string, serviceName string,
 		log.Infof("### Namespace:%s,serviceName:%s, Init K8sSolver Success ...", namespace, serviceName)
 	} else {
 		log.Errorf("### Namespace:%s,serviceName:%s, Init K8sSolver Failed ...", namespace, serviceName)
+		return
 	}
 
 	// etcdHost := viper.GetString("etcd_host")
@@ -0,0 +1,58 @@
+package static
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc/resolver"
+)
+
+// 固定地址列表，用于本地开发、docker-compose 等没有服务发现的环境
+//
+//	conn, err := balancer.NewRPCClient("static:///127.0.0.1:9001,127.0.0.1:9002")
+const Scheme = "static"
+
+func init() {
+	resolver.Register(&staticBuilder{})
+}
+
+// 解析逗号分隔的 host:port 列表
+func ParseAddresses(endpoint string) ([]resolver.Address, error) {
+	var addrs []resolver.Address
+	for _, addr := range strings.Split(endpoint, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return nil, fmt.Errorf("static resolver: invalid address %q: %v", addr, err)
+		}
+		addrs = append(addrs, resolver.Address{Addr: addr})
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("static resolver: no address in %q", endpoint)
+	}
+	return addrs, nil
+}
+
+type staticBuilder struct{}
+
+func (*staticBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	addrs, err := ParseAddresses(target.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	cc.UpdateState(resolver.State{Addresses: addrs})
+	return &staticResolver{}, nil
+}
+
+func (*staticBuilder) Scheme() string {
+	return Scheme
+}
+
+type staticResolver struct{}
+
+func (*staticResolver) ResolveNow(resolver.ResolveNowOptions) {}
+
+func (*staticResolver) Close() {}
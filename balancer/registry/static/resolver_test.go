@@ -0,0 +1,17 @@
+package static
+
+import (
+	"testing"
+)
+
+func TestParseAddresses(t *testing.T) {
+	addrs, err := ParseAddresses("127.0.0.1:9001, 127.0.0.1:9002,,[::1]:9003")
+	if err != nil || len(addrs) != 3 || addrs[1].Addr != "127.0.0.1:9002" || addrs[2].Addr != "[::1]:9003" {
+		t.Fatalf("addrs: %v %v", addrs, err)
+	}
+	for _, endpoint := range []string{"", "127.0.0.1", "a:1,b"} {
+		if _, err := ParseAddresses(endpoint); err == nil {
+			t.Fatalf("expect error for %q", endpoint)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+// 不经过 shell 直接执行的命令，参数原样传给进程，不存在注入问题
+//
+//	res, err := command.New("ls", "-la").Dir("/tmp").Timeout(time.Second).Run(ctx)
+type Cmd struct {
+	name    string
+	args    []string
+	env     []string
+	dir     string
+	stdin   io.Reader
+	timeout time.Duration
+
+	// 除了写入 Result 之外，同时写到这里
+	stdout io.Writer
+	stderr io.Writer
+
+	onLine    []func(Line)
+	lineChans []chan<- Line
+	maxOutput int
+	tees      []io.Writer
+	teeFiles  []string
+
+	limits Limits
+	grace  time.Duration
+	// 进程启动后回调，用于 Supervisor 记录 pid
+	onStart func(pid int)
+	// TeeFile 追加写入而不是覆盖，用于 Supervisor 多次运行
+	teeAppend bool
+}
+
+// 执行结果，Run 返回错误时也会填充
+type Result struct {
+	// 被信号杀死或没有启动时为 -1
+	ExitCode int
+	Stdout   string
+	Stderr   string
+	// Stdout 或 Stderr 超过 MaxOutput，中间部分被丢弃
+	Truncated bool
+	Duration  time.Duration
+	TimedOut  bool
+	// 超出 Limits 被杀死时为对应的状态，否则为 OK
+	LimitExceeded RunStatus
+}
+
+func (r *Result) Status() RunStatus {
+	switch {
+	case r.TimedOut:
+		return TimeExceedLimit
+	case r.LimitExceeded != OK:
+		return r.LimitExceeded
+	case r.ExitCode == 0:
+		return OK
+	}
+	return ERROR
+}
+
+func New(name string, args ...string) *Cmd {
+	return &Cmd{name: name, args: args}
+}
+
+// 追加参数
+func (c *Cmd) Args(args ...string) *Cmd {
+	c.args = append(c.args, args...)
+	return c
+}
+
+// 追加环境变量，格式为 KEY=VALUE，在当前进程的环境变量基础上覆盖
+func (c *Cmd) Env(env ...string) *Cmd {
+	c.env = append(c.env, env...)
+	return c
+}
+
+func (c *Cmd) Dir(dir string) *Cmd {
+	c.dir = dir
+	return c
+}
+
+func (c *Cmd) Stdin(stdin io.Reader) *Cmd {
+	c.stdin = stdin
+	return c
+}
+
+// 超时后杀死整个进程组，0 表示只受 ctx 控制
+func (c *Cmd) Timeout(timeout time.Duration) *Cmd {
+	c.timeout = timeout
+	return c
+}
+
+// ctx 结束或超时时先向进程组发送 SIGTERM，grace 之后仍未退出再 SIGKILL，0 表示直接 SIGKILL
+func (c *Cmd) GracePeriod(grace time.Duration) *Cmd {
+	c.grace = grace
+	return c
+}
+
+func (c *Cmd) String() string {
+	return fmt.Sprint(append([]string{c.name}, c.args...))
+}
+
+// 阻塞执行，退出码不为 0、超时或 ctx 结束时返回错误
+// ctx 结束或超时时会杀死命令启动的整个进程组
+// tracing.Enable 时记录一个 span
+func (c *Cmd) Run(ctx context.Context) (*Result, error) {
+	var res *Result
+	err := traceRun(ctx, "COMMAND "+c.name, func(ctx context.Context, sp opentracing.Span) error {
+		var err error
+		res, err = c.run(ctx)
+		traceResult(sp, c.argv(), res)
+		return err
+	})
+	return res, err
+}
+
+func (c *Cmd) run(ctx context.Context) (*Result, error) {
+	p, err := c.start(c.stdin, nil)
+	if err != nil {
+		return &Result{ExitCode: -1}, err
+	}
+	return p.wait(ctx)
+}
+
+// 已经启动的命令
+type process struct {
+	c       *Cmd
+	cmd     *exec.Cmd
+	out     *outputs
+	limited *limitedProcess
+	start   time.Time
+}
+
+// stdout 不为 nil 时直接作为进程的 stdout，不再保存、回调或者 tee，用于 Pipeline 连接下一个命令
+func (c *Cmd) start(stdin io.Reader, stdout *os.File) (*process, error) {
+	out, err := c.openOutputs()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(c.name, c.args...)
+	cmd.Dir = c.dir
+	cmd.Stdin = stdin
+	cmd.Stdout = out.stdoutW
+	if stdout != nil {
+		cmd.Stdout = stdout
+	}
+	cmd.Stderr = out.stderrW
+	if len(c.env) > 0 {
+		cmd.Env = append(os.Environ(), c.env...)
+	}
+	setProcessGroup(cmd)
+
+	p := &process{c: c, cmd: cmd, out: out, start: time.Now()}
+	if c.limits.IsZero() {
+		err = cmd.Start()
+	} else {
+		p.limited, err = startWithLimits(cmd, c.limits)
+	}
+	if err != nil {
+		out.close()
+		return nil, err
+	}
+	if c.onStart != nil {
+		c.onStart(cmd.Process.Pid)
+	}
+	return p, nil
+}
+
+func (p *process) wait(ctx context.Context) (*Result, error) {
+	c, cmd := p.c, p.cmd
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	done := make(chan struct{})
+	killed := make(chan struct{})
+	go func() {
+		defer close(killed)
+		select {
+		case <-ctx.Done():
+		case <-done:
+			return
+		}
+		if c.grace > 0 {
+			terminateProcessGroup(cmd)
+			select {
+			case <-done:
+				return
+			case <-time.After(c.grace):
+			}
+		}
+		killProcessGroup(cmd)
+	}()
+	err := cmd.Wait()
+	close(done)
+	<-killed
+	if closeErr := p.out.close(); err == nil {
+		err = closeErr
+	}
+
+	res := &Result{ExitCode: -1}
+	res.Duration = time.Since(p.start)
+	res.Stdout = p.out.stdout.String()
+	res.Stderr = p.out.stderr.String()
+	res.Truncated = p.out.stdout.Truncated() || p.out.stderr.Truncated()
+	if cmd.ProcessState != nil {
+		res.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	if p.limited != nil {
+		res.LimitExceeded = p.limited.finish(cmd.ProcessState)
+		if res.LimitExceeded != OK {
+			return res, fmt.Errorf("command: %s exceeded limit (status %d): %v", c, res.LimitExceeded, err)
+		}
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil && res.ExitCode != 0 {
+		res.TimedOut = ctxErr == context.DeadlineExceeded
+		return res, ctxErr
+	}
+	return res, err
+}
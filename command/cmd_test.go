@@ -0,0 +1,75 @@
+package command
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCmdRun(t *testing.T) {
+	ctx := context.Background()
+
+	// 参数不经过 shell 解释
+	res, err := New("echo", "a; echo b", "$HOME").Run(ctx)
+	if err != nil || res.Stdout != "a; echo b $HOME\n" || res.ExitCode != 0 || res.Status() != OK {
+		t.Fatalf("echo: %+v %v", res, err)
+	}
+
+	res, err = New("sh", "-c", "echo out; echo err >&2; exit 3").Run(ctx)
+	if err == nil || res.ExitCode != 3 || res.Stdout != "out\n" || res.Stderr != "err\n" || res.Status() != ERROR {
+		t.Fatalf("exit: %+v %v", res, err)
+	}
+
+	dir, err := ioutil.TempDir("", "command")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dir, _ = filepath.EvalSymlinks(dir)
+	res, err = New("sh", "-c", `echo "$FOO"; pwd`).Env("FOO=bar").Dir(dir).Run(ctx)
+	if err != nil || res.Stdout != "bar\n"+dir+"\n" {
+		t.Fatalf("env/dir: %+v %v", res, err)
+	}
+
+	res, err = New("cat").Stdin(strings.NewReader("hello")).Run(ctx)
+	if err != nil || res.Stdout != "hello" {
+		t.Fatalf("stdin: %+v %v", res, err)
+	}
+
+	if _, err = New("command-not-exist").Run(ctx); err == nil {
+		t.Fatal("expect start error")
+	}
+}
+
+func TestCmdTimeoutKillsGroup(t *testing.T) {
+	// 后台的 sleep 继承了 stdout，只杀死 sh 的话 Run 会一直等到它结束
+	res, err := New("sh", "-c", "sleep 10 & sleep 10").Timeout(200 * time.Millisecond).Run(context.Background())
+	if err != context.DeadlineExceeded || !res.TimedOut || res.Status() != TimeExceedLimit {
+		t.Fatalf("timeout: %+v %v", res, err)
+	}
+	if res.Duration > 2*time.Second {
+		t.Fatalf("took %v", res.Duration)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	res, err = New("sleep", "10").Run(ctx)
+	if err != context.Canceled || res.TimedOut {
+		t.Fatalf("cancel: %+v %v", res, err)
+	}
+}
+
+func TestRunWithTimeLimitStatus(t *testing.T) {
+	status, output := RunWithTimeLimit("echo hi; sleep 10", 1, false)
+	if status != TimeExceedLimit || output != "hi\n" {
+		t.Fatalf("%v %q", status, output)
+	}
+	status, output = RunWithTimeLimit("echo hi >&2", 1, false)
+	if status != OK || output != "hi\n" {
+		t.Fatalf("%v %q", status, output)
+	}
+}
@@ -0,0 +1,24 @@
+//go:build windows
+// +build windows
+
+package command
+
+import (
+	"os/exec"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {
+}
+
+// windows 下没有进程组，只杀死直接启动的进程
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	cmd.Process.Kill()
+}
+
+// windows 下没有 SIGTERM，直接杀死
+func terminateProcessGroup(cmd *exec.Cmd) {
+	killProcessGroup(cmd)
+}
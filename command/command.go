@@ -1,26 +1,23 @@
 package command
 
 import (
-	"bufio"
 	"bytes"
-	"github.com/DoOR-Team/goutils/log"
+	"context"
 	"io"
 	"os"
 	"os/exec"
 	"time"
+
+	"github.com/DoOR-Team/goutils/log"
 )
 
 type FileWriterBuffer struct {
 	File *os.File
 }
 
+// 每次都直接写入文件，不再额外包一层 bufio.Writer
 func (buffer *FileWriterBuffer) Write(p []byte) (n int, err error) {
-	writer := bufio.NewWriter(buffer.File)
-	n, err = writer.Write(p)
-	if err == nil {
-		err = writer.Flush()
-	}
-	return n, err
+	return buffer.File.Write(p)
 }
 
 var ShellToUse = "bash"
@@ -78,8 +75,13 @@ const (
 	ERROR           RunStatus = 1
 	TimeExceedLimit RunStatus = 2
 	UnKnown         RunStatus = 3
+	// 以下为超出 Limits 的情况，见 Limits 的说明
+	CPUTimeExceedLimit RunStatus = 4
+	MemoryExceedLimit  RunStatus = 5
+	ProcessExceedLimit RunStatus = 6
 )
 
+// Deprecated: RunWithTimeLimit 已经改用 Cmd 的超时控制，不再使用
 type TimeChecker struct {
 	Ch          *chan RunStatus
 	MaxTime     int
@@ -105,48 +107,29 @@ func (tc *TimeChecker) Run() {
 	go tc.timeExceed()
 }
 
-func run(command string, ch *chan RunStatus, out io.Writer, pid **exec.Cmd) {
-	err := ShelloutWithBuffer(command, out, pid)
-	if err != nil {
-		log.Error(err)
-		*ch <- ERROR
-		return
-	}
-	*ch <- OK
-}
+// RunWithTimeLimit 返回的输出最多保留的字节数，超出时保留开头和结尾
+var MaxTimeLimitOutput = 16 << 20
 
+// 通过 bash -c 执行，超时后杀死整个进程组；command 中不要拼接外部输入，否则请使用 New
 func RunWithTimeLimit(command string, timeout int, verbose bool) (RunStatus, string) {
+	return RunWithLimits(command, timeout, verbose, Limits{})
+}
 
-	var ch chan RunStatus
-	ch = make(chan RunStatus, 2)
-	tc := &TimeChecker{
-		Ch: &ch,
-		// 多给1s容器启动时间
-		MaxTime: timeout,
-		Running: true,
-	}
-	tc.Run()
-
-	var output bytes.Buffer
-	var pid *exec.Cmd
-
+// 与 RunWithTimeLimit 相同，另外限制 CPU、内存等资源，目前只支持 linux
+func RunWithLimits(command string, timeout int, verbose bool, limits Limits) (RunStatus, string) {
+	output := newCappedBuffer(MaxTimeLimitOutput)
+	shared := &lockedWriter{w: output}
+	// stdout、stderr 合并到 output 里，Result 中不再重复保存
+	cmd := New(ShellToUse, "-c", command).Timeout(time.Duration(timeout) * time.Second).MaxOutput(-1).Limits(limits)
 	if verbose {
-		go run(command, &ch, os.Stdout, &pid)
+		cmd.stdout, cmd.stderr = os.Stdout, os.Stdout
 	} else {
-		go run(command, &ch, &output, &pid)
+		cmd.stdout, cmd.stderr = shared, shared
 	}
 
-	for {
-		select {
-		case v := <-ch:
-			// log.Println(v, solverRunner.RunningTime)
-			if pid != nil {
-				pid.Process.Kill()
-			}
-			return v, output.String()
-			// default:
-			// 	// fmt.Println("get data timeout")
-			// 	time.Sleep(time.Second)
-		}
+	res, err := cmd.Run(context.Background())
+	if err != nil && res.Status() == ERROR {
+		log.Error(err)
 	}
+	return res.Status(), output.String()
 }
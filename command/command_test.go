@@ -37,7 +37,7 @@ pwd
 ls -la
 sleep 5
 ls -la
-`, 10)
+`, 10, false)
 	log.Info(status)
 	log.Info(output)
 
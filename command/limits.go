@@ -0,0 +1,49 @@
+package command
+
+import (
+	"time"
+)
+
+// 单个命令的资源限制，零值表示不限制，目前只支持 linux
+//
+// rlimit 在命令 exec 之后、执行第一条指令之前设置，子进程会继承：
+//   - CPUTime 超出时进程被 SIGXCPU/SIGKILL 杀死，状态为 CPUTimeExceedLimit
+//   - AddressSpace、OpenFiles、Processes 超出时只是分配内存、打开文件、fork 失败，进程自己决定是否退出，状态仍为 ERROR
+//   - Processes 按用户计算（RLIMIT_NPROC），同一用户已有的进程也算在内
+//
+// Cgroup* 需要 cgroup v2，并且 CgroupParent 可写、已经开启对应的 controller，不满足时只记录日志并忽略：
+//   - CgroupMemory 超出时进程被 OOM 杀死，状态为 MemoryExceedLimit
+//   - CgroupPids 超出时 fork 失败，进程异常退出时状态为 ProcessExceedLimit
+//   - CgroupCPU 只限制速度，不会杀死进程
+type Limits struct {
+	CPUTime      time.Duration
+	AddressSpace uint64
+	OpenFiles    uint64
+	Processes    uint64
+
+	CgroupMemory uint64
+	// 可以使用的 CPU 核数，如 0.5
+	CgroupCPU  float64
+	CgroupPids uint64
+	// 在这个目录下为每个命令创建 cgroup，默认为 DefaultCgroupParent
+	CgroupParent string
+}
+
+var DefaultCgroupParent = "/sys/fs/cgroup"
+
+func (l Limits) IsZero() bool {
+	return l == Limits{}
+}
+
+func (l Limits) hasRlimit() bool {
+	return l.CPUTime > 0 || l.AddressSpace > 0 || l.OpenFiles > 0 || l.Processes > 0
+}
+
+func (l Limits) hasCgroup() bool {
+	return l.CgroupMemory > 0 || l.CgroupCPU > 0 || l.CgroupPids > 0
+}
+
+func (c *Cmd) Limits(limits Limits) *Cmd {
+	c.limits = limits
+	return c
+}
@@ -0,0 +1,255 @@
+package command
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/rs/xid"
+
+	"github.com/DoOR-Team/goutils/log"
+)
+
+// cpu.max 的周期
+const cgroupCPUPeriod = 100000
+
+type limitedProcess struct {
+	limits Limits
+	cgroup string
+}
+
+// 以 ptrace 方式启动，子进程 exec 之后会停下来，此时设置 rlimit、加入 cgroup 再让它继续执行
+func startWithLimits(cmd *exec.Cmd, limits Limits) (*limitedProcess, error) {
+	p := &limitedProcess{limits: limits}
+	if limits.hasCgroup() {
+		dir, err := createCgroup(limits)
+		if err != nil {
+			log.Warnf("[command] cgroup 不可用，忽略 cgroup 限制: %v", err)
+		} else {
+			p.cgroup = dir
+		}
+	}
+
+	// ptrace 相关的调用必须在同一个线程上
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Ptrace = true
+	if err := cmd.Start(); err != nil {
+		p.cleanup()
+		return nil, err
+	}
+
+	pid := cmd.Process.Pid
+	err := waitExecStop(pid)
+	if err == nil {
+		err = p.apply(pid)
+	}
+	if detachErr := syscall.PtraceDetach(pid); err == nil && detachErr != nil {
+		err = fmt.Errorf("command: ptrace detach: %v", detachErr)
+	}
+	if err != nil {
+		killProcessGroup(cmd)
+		cmd.Wait()
+		p.cleanup()
+		return nil, err
+	}
+	return p, nil
+}
+
+func waitExecStop(pid int) error {
+	for {
+		var ws syscall.WaitStatus
+		_, err := syscall.Wait4(pid, &ws, syscall.WALL, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("command: wait for exec: %v", err)
+		}
+		if !ws.Stopped() {
+			return fmt.Errorf("command: process exited before limits were applied: %v", ws)
+		}
+		return nil
+	}
+}
+
+type rlimitSetting struct {
+	resource int
+	cur, max uint64
+}
+
+func (p *limitedProcess) apply(pid int) error {
+	l := p.limits
+	rlimits := []rlimitSetting{}
+	if l.CPUTime > 0 {
+		secs := uint64((l.CPUTime + time.Second - 1) / time.Second)
+		// 先收到 SIGXCPU，1 秒后还没退出再 SIGKILL
+		rlimits = append(rlimits, rlimitSetting{syscall.RLIMIT_CPU, secs, secs + 1})
+	}
+	if l.AddressSpace > 0 {
+		rlimits = append(rlimits, rlimitSetting{syscall.RLIMIT_AS, l.AddressSpace, l.AddressSpace})
+	}
+	if l.OpenFiles > 0 {
+		rlimits = append(rlimits, rlimitSetting{syscall.RLIMIT_NOFILE, l.OpenFiles, l.OpenFiles})
+	}
+	if l.Processes > 0 {
+		rlimits = append(rlimits, rlimitSetting{rlimitNproc, l.Processes, l.Processes})
+	}
+	for _, r := range rlimits {
+		if err := prlimit(pid, r.resource, &syscall.Rlimit{Cur: r.cur, Max: r.max}); err != nil {
+			return fmt.Errorf("command: set rlimit %d: %v", r.resource, err)
+		}
+	}
+
+	if p.cgroup != "" {
+		if err := writeCgroupFile(p.cgroup, "cgroup.procs", strconv.Itoa(pid)); err != nil {
+			log.Warnf("[command] 加入 cgroup 失败，忽略 cgroup 限制: %v", err)
+			p.cleanup()
+		}
+	}
+	return nil
+}
+
+// syscall 包里没有定义 RLIMIT_NPROC
+const rlimitNproc = 6
+
+func prlimit(pid int, resource int, limit *syscall.Rlimit) error {
+	_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64, uintptr(pid), uintptr(resource),
+		uintptr(unsafe.Pointer(limit)), 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// 进程结束后判断是否因为超出限制被杀死，并清理 cgroup
+func (p *limitedProcess) finish(state *os.ProcessState) RunStatus {
+	defer p.cleanup()
+	if state == nil {
+		return OK
+	}
+
+	if p.limits.CPUTime > 0 {
+		if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			used := state.UserTime() + state.SystemTime()
+			if ws.Signal() == syscall.SIGXCPU || ws.Signal() == syscall.SIGKILL && used >= p.limits.CPUTime {
+				return CPUTimeExceedLimit
+			}
+		}
+	}
+
+	if p.cgroup != "" {
+		if p.limits.CgroupMemory > 0 && readCgroupEvents(p.cgroup, "memory.events")["oom_kill"] > 0 {
+			return MemoryExceedLimit
+		}
+		if p.limits.CgroupPids > 0 && !state.Success() && readCgroupEvents(p.cgroup, "pids.events")["max"] > 0 {
+			return ProcessExceedLimit
+		}
+	}
+	return OK
+}
+
+func createCgroup(l Limits) (string, error) {
+	parent := l.CgroupParent
+	if parent == "" {
+		parent = DefaultCgroupParent
+	}
+	if _, err := os.Stat(filepath.Join(parent, "cgroup.controllers")); err != nil {
+		return "", fmt.Errorf("%s is not a cgroup v2 directory", parent)
+	}
+
+	controllers := []string{}
+	if l.CgroupMemory > 0 {
+		controllers = append(controllers, "+memory")
+	}
+	if l.CgroupCPU > 0 {
+		controllers = append(controllers, "+cpu")
+	}
+	if l.CgroupPids > 0 {
+		controllers = append(controllers, "+pids")
+	}
+	// 已经开启或者没有权限时会失败，以下面写入限制的结果为准
+	writeCgroupFile(parent, "cgroup.subtree_control", strings.Join(controllers, " "))
+
+	dir := filepath.Join(parent, "goutils-command-"+xid.New().String())
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return "", err
+	}
+	files := map[string]string{}
+	if l.CgroupMemory > 0 {
+		files["memory.max"] = strconv.FormatUint(l.CgroupMemory, 10)
+		// 不使用 swap，否则超出内存后只是变慢
+		files["memory.swap.max"] = "0"
+	}
+	if l.CgroupCPU > 0 {
+		files["cpu.max"] = fmt.Sprintf("%d %d", int64(l.CgroupCPU*cgroupCPUPeriod), cgroupCPUPeriod)
+	}
+	if l.CgroupPids > 0 {
+		files["pids.max"] = strconv.FormatUint(l.CgroupPids, 10)
+	}
+	for name, value := range files {
+		err := writeCgroupFile(dir, name, value)
+		if err != nil && name != "memory.swap.max" {
+			os.Remove(dir)
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+func writeCgroupFile(dir, name, value string) error {
+	return ioutil.WriteFile(filepath.Join(dir, name), []byte(value), 0644)
+}
+
+// 读取 memory.events、pids.events 这类 key value 格式的文件
+func readCgroupEvents(dir, name string) map[string]int64 {
+	events := map[string]int64{}
+	f, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return events
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if v, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			events[fields[0]] = v
+		}
+	}
+	return events
+}
+
+// 杀死 cgroup 中残留的进程并删除 cgroup
+func (p *limitedProcess) cleanup() {
+	if p.cgroup == "" {
+		return
+	}
+	dir := p.cgroup
+	p.cgroup = ""
+	// cgroup.kill 需要 5.14 以上的内核，失败时依赖进程组已经被杀死
+	writeCgroupFile(dir, "cgroup.kill", "1")
+	var err error
+	for i := 0; i < 50; i++ {
+		if err = os.Remove(dir); err == nil || os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	log.Warnf("[command] 删除 cgroup %s 失败: %v", dir, err)
+}
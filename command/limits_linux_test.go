@@ -0,0 +1,65 @@
+package command
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimitsRlimit(t *testing.T) {
+	res, err := New("cat", "/proc/self/limits").Limits(Limits{
+		CPUTime:      1500 * time.Millisecond,
+		AddressSpace: 1 << 30,
+		OpenFiles:    32,
+		Processes:    1000,
+	}).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expect := range []string{
+		`Max cpu time\s+2\s+3\s+seconds`,
+		`Max address space\s+1073741824\s+1073741824\s+bytes`,
+		`Max open files\s+32\s+32\s+files`,
+		`Max processes\s+1000\s+1000\s+processes`,
+	} {
+		if !regexp.MustCompile(expect).MatchString(res.Stdout) {
+			t.Fatalf("%s not found in\n%s", expect, res.Stdout)
+		}
+	}
+	if res.Status() != OK {
+		t.Fatalf("status %d", res.Status())
+	}
+}
+
+func TestLimitsCPUTime(t *testing.T) {
+	res, err := New("sh", "-c", "while :; do :; done").
+		Limits(Limits{CPUTime: time.Second}).
+		Timeout(10 * time.Second).
+		Run(context.Background())
+	if err == nil || res.TimedOut || res.LimitExceeded != CPUTimeExceedLimit || res.Status() != CPUTimeExceedLimit {
+		t.Fatalf("%+v %v", res, err)
+	}
+
+	status, _ := RunWithLimits("while :; do :; done", 10, false, Limits{CPUTime: time.Second})
+	if status != CPUTimeExceedLimit {
+		t.Fatalf("status %d", status)
+	}
+}
+
+func TestLimitsCgroupMemory(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join(DefaultCgroupParent, "cgroup.controllers"))
+	if err != nil || !strings.Contains(string(data), "memory") {
+		t.Skip("cgroup v2 memory controller not available")
+	}
+	res, _ := New("sh", "-c", "x=a; while :; do x=$x$x; done").
+		Limits(Limits{CgroupMemory: 32 << 20}).
+		Timeout(20 * time.Second).
+		Run(context.Background())
+	if res.Status() != MemoryExceedLimit {
+		t.Skipf("cgroup not usable here: %+v", res)
+	}
+}
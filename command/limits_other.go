@@ -0,0 +1,20 @@
+//go:build !linux
+// +build !linux
+
+package command
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+type limitedProcess struct{}
+
+func startWithLimits(cmd *exec.Cmd, limits Limits) (*limitedProcess, error) {
+	return nil, errors.New("command: resource limits are only supported on linux")
+}
+
+func (p *limitedProcess) finish(state *os.ProcessState) RunStatus {
+	return OK
+}
@@ -0,0 +1,280 @@
+package command
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+type Stream int
+
+const (
+	Stdout Stream = iota
+	Stderr
+)
+
+func (s Stream) String() string {
+	if s == Stderr {
+		return "stderr"
+	}
+	return "stdout"
+}
+
+// 一行输出，不包含换行符
+type Line struct {
+	Stream Stream
+	Text   string
+}
+
+// 单行超过这个长度时直接切开，避免一直不换行的输出占满内存
+const maxLineSize = 64 * 1024
+
+// 逐行回调，stdout 和 stderr 的回调不会并发执行
+func (c *Cmd) OnLine(fn func(line Line)) *Cmd {
+	c.onLine = append(c.onLine, fn)
+	return c
+}
+
+// 逐行写入 ch，Run 返回时所有行都已经写入；ch 由调用方关闭，同一个 Cmd 可以多次 Run
+// ch 需要及时消费，否则会阻塞命令的输出
+func (c *Cmd) LineChan(ch chan<- Line) *Cmd {
+	c.lineChans = append(c.lineChans, ch)
+	return c
+}
+
+// Result 中的 Stdout、Stderr 各自最多保留 size 字节，超出时保留开头和结尾各一半，中间替换为 truncated 标记
+// 0 表示不限制，小于 0 表示不保存
+func (c *Cmd) MaxOutput(size int) *Cmd {
+	c.maxOutput = size
+	return c
+}
+
+// stdout 和 stderr 同时写入 w
+func (c *Cmd) Tee(w io.Writer) *Cmd {
+	c.tees = append(c.tees, w)
+	return c
+}
+
+// stdout 和 stderr 同时写入文件，文件不存在时创建，已存在时覆盖
+func (c *Cmd) TeeFile(path string) *Cmd {
+	c.teeFiles = append(c.teeFiles, path)
+	return c
+}
+
+// 保留开头和结尾的输出，中间超出的部分丢弃
+type cappedBuffer struct {
+	limit int
+	head  []byte
+	// 环形缓冲，写满后 pos 为最早的字节
+	tail  []byte
+	pos   int
+	total int64
+}
+
+func newCappedBuffer(limit int) *cappedBuffer {
+	return &cappedBuffer{limit: limit}
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	b.total += int64(n)
+	if b.limit < 0 {
+		return n, nil
+	}
+	if b.limit == 0 {
+		b.head = append(b.head, p...)
+		return n, nil
+	}
+
+	headLimit := b.limit / 2
+	if room := headLimit - len(b.head); room > 0 {
+		if room > len(p) {
+			room = len(p)
+		}
+		b.head = append(b.head, p[:room]...)
+		p = p[room:]
+	}
+
+	tailLimit := b.limit - headLimit
+	if len(p) >= tailLimit {
+		b.tail = append(b.tail[:0], p[len(p)-tailLimit:]...)
+		b.pos = 0
+		return n, nil
+	}
+	for len(p) > 0 {
+		if len(b.tail) < tailLimit {
+			room := tailLimit - len(b.tail)
+			if room > len(p) {
+				room = len(p)
+			}
+			b.tail = append(b.tail, p[:room]...)
+			p = p[room:]
+			continue
+		}
+		copied := copy(b.tail[b.pos:], p)
+		b.pos = (b.pos + copied) % tailLimit
+		p = p[copied:]
+	}
+	return n, nil
+}
+
+func (b *cappedBuffer) Truncated() bool {
+	return b.limit >= 0 && b.total > int64(len(b.head)+len(b.tail))
+}
+
+func (b *cappedBuffer) String() string {
+	var buf bytes.Buffer
+	buf.Write(b.head)
+	if b.Truncated() {
+		fmt.Fprintf(&buf, "\n...[truncated %d bytes]...\n", b.total-int64(len(b.head)+len(b.tail)))
+	}
+	buf.Write(b.tail[b.pos:])
+	buf.Write(b.tail[:b.pos])
+	return buf.String()
+}
+
+// 把 stdout、stderr 的输出按行分发
+type lineSink struct {
+	mu    sync.Mutex
+	fns   []func(Line)
+	chans []chan<- Line
+}
+
+func (s *lineSink) emit(line Line) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, fn := range s.fns {
+		fn(line)
+	}
+	for _, ch := range s.chans {
+		ch <- line
+	}
+}
+
+type lineWriter struct {
+	sink   *lineSink
+	stream Stream
+	buf    []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			w.buf = append(w.buf, p...)
+			for len(w.buf) >= maxLineSize {
+				w.sink.emit(Line{Stream: w.stream, Text: string(w.buf[:maxLineSize])})
+				w.buf = w.buf[maxLineSize:]
+			}
+			break
+		}
+		w.buf = append(w.buf, p[:i]...)
+		w.sink.emit(Line{Stream: w.stream, Text: string(bytes.TrimSuffix(w.buf, []byte("\r")))})
+		w.buf = w.buf[:0]
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+// 输出结束时最后一行可能没有换行
+func (w *lineWriter) flush() {
+	if len(w.buf) > 0 {
+		w.sink.emit(Line{Stream: w.stream, Text: string(w.buf)})
+		w.buf = nil
+	}
+}
+
+// 多个 goroutine 共用的 writer
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+// 一次 Run 用到的所有输出目标
+type outputs struct {
+	stdout, stderr *cappedBuffer
+	stdoutW        io.Writer
+	stderrW        io.Writer
+
+	lines       []*lineWriter
+	sink        *lineSink
+	files       []*os.File
+	fileWriters []*bufio.Writer
+}
+
+func (c *Cmd) openOutputs() (*outputs, error) {
+	o := &outputs{
+		stdout: newCappedBuffer(c.maxOutput),
+		stderr: newCappedBuffer(c.maxOutput),
+	}
+	stdoutWs := []io.Writer{o.stdout}
+	stderrWs := []io.Writer{o.stderr}
+	if c.stdout != nil {
+		stdoutWs = append(stdoutWs, c.stdout)
+	}
+	if c.stderr != nil {
+		stderrWs = append(stderrWs, c.stderr)
+	}
+
+	tees := append([]io.Writer{}, c.tees...)
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if c.teeAppend {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	for _, path := range c.teeFiles {
+		f, err := os.OpenFile(path, flag, 0666)
+		if err != nil {
+			o.close()
+			return nil, err
+		}
+		w := bufio.NewWriter(f)
+		o.files = append(o.files, f)
+		o.fileWriters = append(o.fileWriters, w)
+		tees = append(tees, w)
+	}
+	for _, tee := range tees {
+		shared := &lockedWriter{w: tee}
+		stdoutWs = append(stdoutWs, shared)
+		stderrWs = append(stderrWs, shared)
+	}
+
+	if len(c.onLine) > 0 || len(c.lineChans) > 0 {
+		o.sink = &lineSink{fns: c.onLine, chans: c.lineChans}
+		out := &lineWriter{sink: o.sink, stream: Stdout}
+		errOut := &lineWriter{sink: o.sink, stream: Stderr}
+		o.lines = []*lineWriter{out, errOut}
+		stdoutWs = append(stdoutWs, out)
+		stderrWs = append(stderrWs, errOut)
+	}
+
+	o.stdoutW = io.MultiWriter(stdoutWs...)
+	o.stderrW = io.MultiWriter(stderrWs...)
+	return o, nil
+}
+
+// 命令结束后调用，返回写文件的错误
+func (o *outputs) close() error {
+	for _, w := range o.lines {
+		w.flush()
+	}
+	var err error
+	for i, f := range o.files {
+		if e := o.fileWriters[i].Flush(); e != nil && err == nil {
+			err = e
+		}
+		if e := f.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
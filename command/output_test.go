@@ -0,0 +1,99 @@
+package command
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestCappedBuffer(t *testing.T) {
+	for _, chunk := range []int{1, 3, 7, 16} {
+		b := newCappedBuffer(10)
+		data := "0123456789abcdef"
+		for i := 0; i < len(data); i += chunk {
+			end := i + chunk
+			if end > len(data) {
+				end = len(data)
+			}
+			b.Write([]byte(data[i:end]))
+		}
+		if got := b.String(); got != "01234\n...[truncated 6 bytes]...\nbcdef" || !b.Truncated() {
+			t.Fatalf("chunk %d: %q", chunk, got)
+		}
+	}
+
+	b := newCappedBuffer(10)
+	b.Write([]byte("0123456789"))
+	if got := b.String(); got != "0123456789" || b.Truncated() {
+		t.Fatalf("%q", got)
+	}
+}
+
+func TestCmdOnLine(t *testing.T) {
+	lines := []string{}
+	res, err := New("sh", "-c", "echo a; echo b >&2; printf c").OnLine(func(line Line) {
+		lines = append(lines, line.Stream.String()+":"+line.Text)
+	}).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(lines)
+	if strings.Join(lines, ",") != "stderr:b,stdout:a,stdout:c" {
+		t.Fatalf("lines %v", lines)
+	}
+	if res.Stdout != "a\nc" || res.Stderr != "b\n" {
+		t.Fatalf("%+v", res)
+	}
+}
+
+func TestCmdLineChan(t *testing.T) {
+	ch := make(chan Line)
+	got := make(chan int)
+	go func() {
+		n := 0
+		for range ch {
+			n++
+		}
+		got <- n
+	}()
+	// 同一个 Cmd 多次 Run，ch 由调用方关闭
+	cmd := New("seq", "1", "1000").LineChan(ch)
+	for i := 0; i < 2; i++ {
+		if _, err := cmd.Run(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(ch)
+	if n := <-got; n != 2000 {
+		t.Fatalf("got %d lines", n)
+	}
+}
+
+func TestCmdMaxOutputAndTeeFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "command")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "out.log")
+
+	res, err := New("seq", "1", "10000").MaxOutput(100).TeeFile(path).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Truncated || !strings.HasPrefix(res.Stdout, "1\n2\n") || !strings.HasSuffix(res.Stdout, "\n10000\n") ||
+		!strings.Contains(res.Stdout, "truncated") {
+		t.Fatalf("stdout %q", res.Stdout)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 10000 {
+		t.Fatalf("file has %d lines", lines)
+	}
+}
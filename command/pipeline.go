@@ -0,0 +1,175 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+// 多个命令的 stdout、stdin 直接用管道相连，相当于 shell 里的 cmd1 | cmd2 | cmd3，但不经过 shell
+//
+//	res, err := command.Pipeline(
+//		command.New("unzip", "-p", "data.zip"),
+//		command.New("grep", "-v", "^#"),
+//		command.New("./solver"),
+//	).Timeout(time.Minute).Run(ctx)
+//
+// 除最后一个命令外，stdout 只写入管道，对应 Cmd 上 stdout 相关的设置不生效；第一个命令之后的 Stdin 也不生效
+type Pipe struct {
+	cmds    []*Cmd
+	timeout time.Duration
+}
+
+func Pipeline(cmds ...*Cmd) *Pipe {
+	return &Pipe{cmds: cmds}
+}
+
+// 整个 pipeline 的超时，超时后杀死所有命令；每个 Cmd 自己的 Timeout 仍然生效
+func (p *Pipe) Timeout(timeout time.Duration) *Pipe {
+	p.timeout = timeout
+	return p
+}
+
+func (p *Pipe) String() string {
+	argv := make([]string, len(p.cmds))
+	for i, c := range p.cmds {
+		argv[i] = c.argv()
+	}
+	return strings.Join(argv, " | ")
+}
+
+// Pipeline 的执行结果
+type PipeResult struct {
+	// 按顺序每个命令的结果，没有启动的命令 ExitCode 为 -1
+	Stages   []*Result
+	Duration time.Duration
+}
+
+// 最后一个命令的输出
+func (r *PipeResult) Stdout() string {
+	if len(r.Stages) == 0 {
+		return ""
+	}
+	return r.Stages[len(r.Stages)-1].Stdout
+}
+
+// 同 shell 的 pipefail，返回最后一个失败的命令的状态，全部成功时为 OK
+func (r *PipeResult) Status() RunStatus {
+	for i := len(r.Stages) - 1; i >= 0; i-- {
+		if status := r.Stages[i].Status(); status != OK {
+			return status
+		}
+	}
+	return OK
+}
+
+// 阻塞执行直到所有命令退出，任意命令失败时返回错误，多个失败时以最后一个为准
+// tracing.Enable 时记录一个 span
+func (p *Pipe) Run(ctx context.Context) (*PipeResult, error) {
+	var res *PipeResult
+	err := traceRun(ctx, "COMMAND PIPELINE", func(ctx context.Context, sp opentracing.Span) error {
+		var err error
+		res, err = p.run(ctx)
+		if res != nil && len(res.Stages) > 0 {
+			last := *res.Stages[len(res.Stages)-1]
+			last.Duration = res.Duration
+			traceResult(sp, p.String(), &last)
+			codes := make([]string, len(res.Stages))
+			for i, stage := range res.Stages {
+				codes[i] = strconv.Itoa(stage.ExitCode)
+			}
+			sp.SetTag("cmd.exit_codes", strings.Join(codes, ","))
+		}
+		return err
+	})
+	return res, err
+}
+
+func (p *Pipe) run(ctx context.Context) (*PipeResult, error) {
+	if len(p.cmds) == 0 {
+		return nil, errors.New("command: empty pipeline")
+	}
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+	// 某个命令启动失败时杀死已经启动的命令
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	n := len(p.cmds)
+	res := &PipeResult{Stages: make([]*Result, n)}
+	for i := range res.Stages {
+		res.Stages[i] = &Result{ExitCode: -1}
+	}
+	errs := make([]error, n)
+	procs := make([]*process, 0, n)
+	start := time.Now()
+
+	stdin := p.cmds[0].stdin
+	var startErr error
+	for i, c := range p.cmds {
+		var r, w *os.File
+		if i < n-1 {
+			var err error
+			r, w, err = os.Pipe()
+			if err != nil {
+				startErr = err
+			}
+		}
+		var proc *process
+		if startErr == nil {
+			proc, startErr = c.start(stdin, w)
+		}
+		// 子进程已经持有管道，父进程这边的要关掉，否则读的一方收不到 EOF
+		if f, ok := stdin.(*os.File); ok && i > 0 {
+			f.Close()
+		}
+		if w != nil {
+			w.Close()
+		}
+		if startErr != nil {
+			if r != nil {
+				r.Close()
+			}
+			errs[i] = startErr
+			cancel()
+			break
+		}
+		procs = append(procs, proc)
+		stdin = r
+	}
+
+	var wg sync.WaitGroup
+	for i, proc := range procs {
+		wg.Add(1)
+		go func(i int, proc *process) {
+			defer wg.Done()
+			res.Stages[i], errs[i] = proc.wait(ctx)
+		}(i, proc)
+	}
+	wg.Wait()
+	res.Duration = time.Since(start)
+
+	if startErr != nil {
+		return res, startErr
+	}
+	for i := n - 1; i >= 0; i-- {
+		if errs[i] == nil {
+			continue
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return res, ctxErr
+		}
+		return res, fmt.Errorf("command: pipeline stage %d (%s): %v", i, p.cmds[i], errs[i])
+	}
+	return res, nil
+}
@@ -0,0 +1,93 @@
+package command
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+
+	"github.com/DoOR-Team/goutils/tracing"
+)
+
+func TestPipeline(t *testing.T) {
+	ctx := context.Background()
+
+	res, err := Pipeline(
+		New("printf", "b\na\n# c\na\n"),
+		New("grep", "-v", "^#"),
+		New("sort", "-u"),
+	).Run(ctx)
+	if err != nil || res.Stdout() != "a\nb\n" || res.Status() != OK || len(res.Stages) != 3 {
+		t.Fatalf("pipeline: %+v %v", res, err)
+	}
+	if res.Stages[0].Stdout != "" {
+		t.Fatalf("intermediate stdout captured: %q", res.Stages[0].Stdout)
+	}
+
+	// 每个命令的退出码分别记录，错误以最后一个失败的命令为准
+	res, err = Pipeline(
+		New("sh", "-c", "echo x; exit 2"),
+		New("sh", "-c", "cat; echo oops >&2; exit 3"),
+		New("cat"),
+	).Run(ctx)
+	if err == nil || !strings.Contains(err.Error(), "stage 1") || res.Status() != ERROR {
+		t.Fatalf("pipefail: %+v %v", res, err)
+	}
+	if res.Stages[0].ExitCode != 2 || res.Stages[1].ExitCode != 3 || res.Stages[2].ExitCode != 0 ||
+		res.Stages[1].Stderr != "oops\n" || res.Stdout() != "x\n" {
+		t.Fatalf("stages: %+v %+v %+v", res.Stages[0], res.Stages[1], res.Stages[2])
+	}
+
+	// 第一个命令使用自己的 Stdin
+	res, err = Pipeline(New("cat").Stdin(strings.NewReader("hello")), New("tr", "a-z", "A-Z")).Run(ctx)
+	if err != nil || res.Stdout() != "HELLO" {
+		t.Fatalf("stdin: %+v %v", res, err)
+	}
+
+	// 启动失败时已经启动的命令被杀死
+	res, err = Pipeline(New("sleep", "10"), New("command-not-exist")).Run(ctx)
+	if err == nil || res.Duration > 2*time.Second || res.Stages[1].ExitCode != -1 {
+		t.Fatalf("start error: %+v %v", res, err)
+	}
+}
+
+func TestPipelineTimeout(t *testing.T) {
+	res, err := Pipeline(New("sleep", "10"), New("cat")).Timeout(200 * time.Millisecond).Run(context.Background())
+	if err != context.DeadlineExceeded || res.Status() != TimeExceedLimit || res.Duration > 2*time.Second {
+		t.Fatalf("timeout: %+v %v", res, err)
+	}
+}
+
+func TestRunTracing(t *testing.T) {
+	tracer := mocktracer.New()
+	oldTracer, oldEnable := opentracing.GlobalTracer(), tracing.Enable
+	opentracing.SetGlobalTracer(tracer)
+	tracing.Enable = true
+	defer func() {
+		opentracing.SetGlobalTracer(oldTracer)
+		tracing.Enable = oldEnable
+	}()
+
+	New("sh", "-c", "echo hi; exit 1").Run(context.Background())
+	Pipeline(New("echo", "a"), New("cat")).Run(context.Background())
+
+	spans := tracer.FinishedSpans()
+	if len(spans) != 2 {
+		t.Fatalf("spans: %d", len(spans))
+	}
+	sp := spans[0]
+	if sp.OperationName != "COMMAND sh" || sp.Tag("cmd.argv") != "sh -c echo hi; exit 1" ||
+		sp.Tag("cmd.exit_code") != 1 || sp.Tag("error") != true {
+		t.Fatalf("cmd span: %s %v", sp.OperationName, sp.Tags())
+	}
+	if out := sp.Logs()[0].Fields[0]; out.ValueString != "hi\n" {
+		t.Fatalf("stdout field: %+v", out)
+	}
+	sp = spans[1]
+	if sp.OperationName != "COMMAND PIPELINE" || sp.Tag("cmd.argv") != "echo a | cat" || sp.Tag("cmd.exit_codes") != "0,0" {
+		t.Fatalf("pipeline span: %s %v", sp.OperationName, sp.Tags())
+	}
+}
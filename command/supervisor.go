@@ -0,0 +1,183 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/DoOR-Team/goutils/log"
+	"github.com/DoOR-Team/goutils/waitgroup"
+)
+
+const defaultGracePeriod = 10 * time.Second
+
+// 常驻运行一个命令，退出后按策略重启，作为 waitgroup.Server 注册：
+//
+//	sup := command.NewSupervisor("worker", command.New("./worker", "-c", "conf.yaml"))
+//	waitgroup.AddModAndWrapServer("worker", sup)
+//
+// Close 时先向进程组发送 SIGTERM，等待 GracePeriod 后仍未退出再 SIGKILL
+// 输出按行写入 log，前缀为 [name:pid]
+type Supervisor struct {
+	name    string
+	cmd     *Cmd
+	options supervisorOptions
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu       sync.Mutex
+	serving  bool
+	closed   bool
+	pid      int
+	restarts int
+	lastExit *Result
+	lastErr  error
+}
+
+type supervisorOptions struct {
+	policy waitgroup.RestartPolicy
+	grace  time.Duration
+}
+
+type SupervisorOption func(*supervisorOptions)
+
+// 进程退出后的重启策略，默认 RestartAlways，等待时间从 1s 开始翻倍，最多 1min
+// 超过 MaxRestarts 或者策略不再重启时 Serve 返回最后一次的错误
+func WithRestartPolicy(policy waitgroup.RestartPolicy) SupervisorOption {
+	return func(o *supervisorOptions) {
+		o.policy = policy
+	}
+}
+
+// Close 时 SIGTERM 之后等待进程退出的时间，默认 10s
+func WithGracePeriod(grace time.Duration) SupervisorOption {
+	return func(o *supervisorOptions) {
+		o.grace = grace
+	}
+}
+
+// cmd 的输出不再保存到 Result，并且不应再单独调用 Run
+// 每次重启都重新打开输出：TeeFile 改为追加写入，LineChan 在多次运行之间保持打开，由调用方关闭
+func NewSupervisor(name string, cmd *Cmd, opts ...SupervisorOption) *Supervisor {
+	options := supervisorOptions{
+		policy: waitgroup.RestartPolicy{Mode: waitgroup.RestartAlways},
+		grace:  defaultGracePeriod,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	s := &Supervisor{
+		name:    name,
+		cmd:     cmd,
+		options: options,
+		done:    make(chan struct{}),
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	cmd.MaxOutput(-1).GracePeriod(options.grace).OnLine(s.logLine)
+	cmd.teeAppend = true
+	cmd.onStart = func(pid int) {
+		s.mu.Lock()
+		s.pid = pid
+		s.mu.Unlock()
+	}
+	return s
+}
+
+func (s *Supervisor) logLine(line Line) {
+	if line.Stream == Stderr {
+		log.Warnf("[%s:%d] %s", s.name, s.Pid(), line.Text)
+		return
+	}
+	log.Infof("[%s:%d] %s", s.name, s.Pid(), line.Text)
+}
+
+// 阻塞运行直到 Close，或者按重启策略不再重启
+func (s *Supervisor) Serve() error {
+	s.mu.Lock()
+	if s.closed || s.serving {
+		s.mu.Unlock()
+		return nil
+	}
+	s.serving = true
+	s.mu.Unlock()
+	defer close(s.done)
+
+	restarts := 0
+	for {
+		log.Infof("[command] %s 启动: %s", s.name, s.cmd)
+		res, err := s.cmd.Run(s.ctx)
+
+		s.mu.Lock()
+		s.pid = 0
+		s.lastExit = res
+		s.lastErr = err
+		closed := s.closed
+		s.mu.Unlock()
+		if closed {
+			return nil
+		}
+		if !s.options.policy.ShouldRestart(err, restarts) {
+			log.Warnf("[command] %s 退出(exit: %d, err: %v)，不再重启", s.name, res.ExitCode, err)
+			return err
+		}
+
+		restarts++
+		wait := s.options.policy.NextBackoff(restarts)
+		log.Warnf("[command] %s 退出(exit: %d, err: %v)，%v 后第 %d 次重启", s.name, res.ExitCode, err, wait, restarts)
+
+		select {
+		case <-time.After(wait):
+		case <-s.ctx.Done():
+			return nil
+		}
+		s.mu.Lock()
+		s.restarts = restarts
+		s.mu.Unlock()
+	}
+}
+
+// 停止重启，结束正在运行的进程并等待 Serve 返回
+func (s *Supervisor) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	serving := s.serving
+	s.mu.Unlock()
+	s.cancel()
+	if serving {
+		<-s.done
+	}
+	return nil
+}
+
+// 进程正在运行时返回 nil，用于就绪检查
+func (s *Supervisor) Check(ctx context.Context) error {
+	if s.Pid() == 0 {
+		return fmt.Errorf("command: %s is not running", s.name)
+	}
+	return nil
+}
+
+// 正在运行的进程 pid，没有运行时为 0
+func (s *Supervisor) Pid() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pid
+}
+
+// 已经重启的次数
+func (s *Supervisor) Restarts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.restarts
+}
+
+// 最近一次退出的结果和错误，还没有退出过时 Result 为 nil
+func (s *Supervisor) LastExit() (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastExit, s.lastErr
+}
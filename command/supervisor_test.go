@@ -0,0 +1,110 @@
+package command
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DoOR-Team/goutils/waitgroup"
+)
+
+var _ waitgroup.Server = (*Supervisor)(nil)
+var _ waitgroup.HealthChecker = (*Supervisor)(nil)
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSupervisorRestart(t *testing.T) {
+	sup := NewSupervisor("exit", New("sh", "-c", "echo hi; exit 3"),
+		WithRestartPolicy(waitgroup.RestartPolicy{Mode: waitgroup.RestartAlways, Backoff: 10 * time.Millisecond, MaxBackoff: 20 * time.Millisecond}))
+	errCh := make(chan error, 1)
+	go func() { errCh <- sup.Serve() }()
+
+	waitFor(t, "restarts", func() bool { return sup.Restarts() >= 3 })
+	res, err := sup.LastExit()
+	if err == nil || res == nil || res.ExitCode != 3 {
+		t.Fatalf("last exit: %+v %v", res, err)
+	}
+	sup.Close()
+	if err := <-errCh; err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+
+	// 超过 MaxRestarts 后 Serve 返回最后一次的错误
+	sup = NewSupervisor("max", New("sh", "-c", "exit 1"),
+		WithRestartPolicy(waitgroup.RestartPolicy{Mode: waitgroup.RestartOnFailure, MaxRestarts: 2, Backoff: time.Millisecond}))
+	if err := sup.Serve(); err == nil || sup.Restarts() != 2 {
+		t.Fatalf("max restarts: %v %d", err, sup.Restarts())
+	}
+	sup.Close()
+}
+
+func TestSupervisorGracefulClose(t *testing.T) {
+	// 收到 SIGTERM 后正常退出
+	sup := NewSupervisor("term", New("sh", "-c", "trap 'exit 0' TERM; while true; do sleep 0.05; done"))
+	go sup.Serve()
+	waitFor(t, "start", func() bool { return sup.Pid() != 0 })
+	if err := sup.Check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	sup.Close()
+	res, err := sup.LastExit()
+	if err != nil || res.ExitCode != 0 || time.Since(start) > 2*time.Second {
+		t.Fatalf("term: %+v %v %v", res, err, time.Since(start))
+	}
+	if sup.Check(context.Background()) == nil {
+		t.Fatal("expect not running")
+	}
+
+	// 忽略 SIGTERM 时等待 grace 后 SIGKILL
+	sup = NewSupervisor("kill", New("sh", "-c", "trap '' TERM; while true; do sleep 0.05; done"), WithGracePeriod(200*time.Millisecond))
+	go sup.Serve()
+	waitFor(t, "start", func() bool { return sup.Pid() != 0 })
+	start = time.Now()
+	sup.Close()
+	res, _ = sup.LastExit()
+	if res.ExitCode != -1 || time.Since(start) < 200*time.Millisecond {
+		t.Fatalf("kill: %+v %v", res, time.Since(start))
+	}
+
+	// 没有 Serve 时 Close 直接返回
+	NewSupervisor("idle", New("true")).Close()
+}
+
+func TestSupervisorOutputsAcrossRestarts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "command")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "out.log")
+
+	ch := make(chan Line, 100)
+	sup := NewSupervisor("outputs", New("sh", "-c", "echo run").LineChan(ch).TeeFile(path),
+		WithRestartPolicy(waitgroup.RestartPolicy{Mode: waitgroup.RestartAlways, MaxRestarts: 2, Backoff: time.Millisecond}))
+	if err := sup.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	sup.Close()
+
+	// 每次运行的输出都保留，LineChan 不会被关闭
+	data, err := ioutil.ReadFile(path)
+	if err != nil || string(data) != "run\nrun\nrun\n" {
+		t.Fatalf("tee file: %q %v", data, err)
+	}
+	if len(ch) != 3 {
+		t.Fatalf("lines: %d", len(ch))
+	}
+	close(ch)
+}
@@ -0,0 +1,46 @@
+package command
+
+import (
+	"context"
+	"strings"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+
+	"github.com/DoOR-Team/goutils/tracing"
+)
+
+// span 里每个输出最多记录的字节数
+const maxTraceOutput = 4096
+
+// tracing.Enable 时在 span 里执行 fn，否则直接执行
+func traceRun(ctx context.Context, operationName string, fn func(ctx context.Context, sp opentracing.Span) error) error {
+	if !tracing.Enable {
+		return fn(ctx, opentracing.NoopTracer{}.StartSpan(operationName))
+	}
+	return tracing.StartSpanWithContextV2(ctx, fn, operationName, "command")
+}
+
+func traceResult(sp opentracing.Span, argv string, res *Result) {
+	if !tracing.Enable || res == nil {
+		return
+	}
+	sp.SetTag("cmd.argv", argv)
+	sp.SetTag("cmd.exit_code", res.ExitCode)
+	sp.SetTag("cmd.duration", res.Duration.String())
+	sp.SetTag("cmd.status", int(res.Status()))
+	sp.LogFields(
+		log.String("stdout", traceOutput(res.Stdout)),
+		log.String("stderr", traceOutput(res.Stderr)),
+	)
+}
+
+func traceOutput(s string) string {
+	b := newCappedBuffer(maxTraceOutput)
+	b.Write([]byte(s))
+	return b.String()
+}
+
+func (c *Cmd) argv() string {
+	return strings.Join(append([]string{c.name}, c.args...), " ")
+}
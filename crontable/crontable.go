@@ -1,152 +1,353 @@
 package crontable
 
 import (
+	"container/heap"
 	"sync"
 	"time"
 
-	"github.com/rs/xid"
 	"golang.org/x/net/context"
+
+	"github.com/DoOR-Team/goutils/log"
+	"github.com/DoOR-Team/goutils/waitgroup"
 )
 
-func NewCron() *CronServer {
+type cronOptions struct {
+	clock       Clock
+	historySize int
+	locker      Locker
+	leaseTTL    time.Duration
+	owner       string
+
+	misfireThreshold time.Duration
+	modName          string
+	jobStore         JobStore
+}
+
+type CronOption func(*cronOptions)
+
+// 替换调度使用的时钟，主要用于测试
+func WithClock(clock Clock) CronOption {
+	return func(options *cronOptions) {
+		options.clock = clock
+	}
+}
+
+func NewCron(opts ...CronOption) *CronServer {
+	cronTable := newCron(opts...)
+	cronTable.started = true
+	go cronTable.start()
+	if cronTable.modName != "" {
+		waitgroup.AddModAndWrapServer(cronTable.modName, cronTable)
+	}
+	return cronTable
+}
+
+func newCron(opts ...CronOption) *CronServer {
+	options := &cronOptions{
+		clock:            realClock{},
+		historySize:      defaultHistorySize,
+		leaseTTL:         defaultLeaseTTL,
+		misfireThreshold: defaultMisfireThreshold,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if options.owner == "" {
+		options.owner = defaultOwner()
+	}
+	if options.jobStore == nil {
+		options.jobStore = NewMemoryJobStore()
+	}
+
 	cronTable := &CronServer{}
+	cronTable.clock = options.clock
+	cronTable.historySize = options.historySize
+	cronTable.locker = options.locker
+	cronTable.leaseTTL = options.leaseTTL
+	cronTable.owner = options.owner
+	cronTable.misfireThreshold = options.misfireThreshold
+	cronTable.modName = options.modName
+	cronTable.jobStore = options.jobStore
+	cronTable.handlers = make(map[string]Handler)
+	cronTable.dirtyJobs = make(map[string]*Job)
+	cronTable.stopCh = make(chan struct{})
+	cronTable.loopDone = make(chan struct{})
 	cronTable.CronMap = make(map[string]*cronInfo)
-	cronTable.myKeepFreshS_C.Channel = time.NewTimer(time.Second * 10000)
-	cronTable.CronInChannel = make(chan string, 2)
-	go cronTable.start()
+	cronTable.runs = make(map[string]*taskRuns)
+	cronTable.CronInChannel = make(chan string, 1)
 	return cronTable
 }
 
 type CronServer struct {
-	myKeepFreshS_C cronOutS_C
-	CronMap        map[string]*cronInfo
-	CronInChannel  chan string
-	lock           sync.Mutex
-}
-type cronOutS_C struct {
-	Channel *time.Timer
-	Tid     []string
+	CronMap       map[string]*cronInfo
+	CronInChannel chan string
+	lock          sync.Mutex
+
+	clock Clock
+	// 按下次执行时间排序的最小堆，与 CronMap 中的任务一一对应
+	queue cronQueue
+	// 自增序号，执行时间相同时按加入顺序执行
+	seq uint64
+
+	historySize int
+	runs        map[string]*taskRuns
+
+	locker   Locker
+	leaseTTL time.Duration
+	owner    string
+
+	misfireThreshold time.Duration
+
+	jobStore JobStore
+	handlers map[string]Handler
+	// 执行后需要写回存储的任务，值为 nil 表示删除
+	dirtyJobs map[string]*Job
+
+	modName  string
+	started  bool
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	loopDone chan struct{}
+	// 正在执行的回调，Stop 时等待它们结束
+	jobs sync.WaitGroup
 }
 
 type cronInfo struct {
-	longestFreshTime  time.Duration
-	nextFreshTime     time.Time
+	// 加上 Jitter 之后的实际执行时间
+	nextFreshTime time.Time
+	// Schedule 计算出的执行时间，下一次按它继续往后推算
+	scheduledTime     time.Time
 	lastFreshTime     time.Time
 	HowOftenKeepFresh time.Duration
+	schedule          Schedule
 	callbackInfo      *CallbackInfoStruct
+	runs              *taskRuns
+	// 通过 AddJob 添加的任务，需要持久化
+	job *Job
+
+	index int
+	seq   uint64
 }
 type CallbackInfoStruct struct {
 	CallbackFunc func(ctx context.Context, tid string) error
 	Context      context.Context
 	TaskId       string
+	// 上一次还没执行完时的处理方式，默认允许并发
+	Overlap OverlapPolicy
+	// 每次执行随机推迟 [0, Jitter)，避免大量任务同时执行，应小于执行间隔
+	Jitter time.Duration
+	// 执行时间已经错过时的处理方式，默认只补执行一次
+	Misfire MisfirePolicy
+	// 多副本部署时只在拿到租约的副本上执行，需要通过 WithLocker 配置租约锁
+	Singleton bool
 }
 
-func (this *CronServer) keepFreshButNotUpdateAll(vv *cronInfo) {
-	now := time.Now()
-	vv.lastFreshTime = now
-	vv.nextFreshTime = now.Add(vv.HowOftenKeepFresh)
-	go vv.callbackInfo.CallbackFunc(vv.callbackInfo.Context, vv.callbackInfo.TaskId)
+type cronQueue []*cronInfo
 
+func (q cronQueue) Len() int {
+	return len(q)
 }
-func (this *CronServer) keepFresh(cronList []*cronInfo) {
-	now := time.Now()
-	for index := range cronList {
-		cronList[index].lastFreshTime = now
-		a := now.Add(cronList[index].HowOftenKeepFresh)
-		cronList[index].nextFreshTime = a
-	}
-	this.updateNextFreshAndCronOutTime()
-	for index := range cronList {
-		go cronList[index].callbackInfo.CallbackFunc(cronList[index].callbackInfo.Context, cronList[index].callbackInfo.TaskId)
+func (q cronQueue) Less(i, j int) bool {
+	if !q[i].nextFreshTime.Equal(q[j].nextFreshTime) {
+		return q[i].nextFreshTime.Before(q[j].nextFreshTime)
 	}
+	return q[i].seq < q[j].seq
 }
-func (this *CronServer) updateNextFreshAndCronOutTime() {
-	this.lock.Lock()
-	var myNextFreshDuration time.Duration = -1
-	var myNextFreshTid []string = []string{}
-	for tid, vv := range this.CronMap {
-		if nil == vv {
-			continue
-		}
-		tempNextFreshDuration := vv.nextFreshTime.Sub(time.Now())
-		if tempNextFreshDuration <= 0 {
-			this.keepFreshButNotUpdateAll(vv)
-			tempNextFreshDuration = vv.HowOftenKeepFresh
+func (q cronQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+func (q *cronQueue) Push(x interface{}) {
+	info := x.(*cronInfo)
+	info.index = len(*q)
+	*q = append(*q, info)
+}
+func (q *cronQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	info := old[n-1]
+	old[n-1] = nil
+	info.index = -1
+	*q = old[:n-1]
+	return info
+}
+
+// 取出所有到期的任务，并按各自的 Schedule 重新排期，调用方需持有 lock
+// 返回需要执行的任务，按 MisfirePolicy 同一个任务可能出现多次或者不出现
+func (this *CronServer) popDue(now time.Time) []*cronInfo {
+	due := []*cronInfo{}
+	for len(this.queue) > 0 && !this.queue[0].nextFreshTime.After(now) {
+		due = append(due, heap.Pop(&this.queue).(*cronInfo))
+	}
+	// 全部取出后再放回，避免间隔不大于 0 的任务在同一轮里反复执行
+	fires := []*cronInfo{}
+	for _, info := range due {
+		info.lastFreshTime = now
+		times := this.reschedule(info, now)
+		for i := 0; i < times; i++ {
+			fires = append(fires, info)
 		}
-		if myNextFreshDuration < 0 {
-			myNextFreshDuration = tempNextFreshDuration
+		if times == 0 {
+			info.runs.record(RunRecord{Start: now, Outcome: OutcomeMisfired})
 		}
-		if tempNextFreshDuration.Seconds() < myNextFreshDuration.Seconds() {
-			myNextFreshDuration = tempNextFreshDuration
-			myNextFreshTid = []string{tid}
-		} else if tempNextFreshDuration.Seconds() == myNextFreshDuration.Seconds() {
-			myNextFreshTid = append(myNextFreshTid, tid)
+		this.markJobLocked(info)
+		if info.nextFreshTime.IsZero() {
+			// 不会再执行了
+			delete(this.CronMap, info.callbackInfo.TaskId)
+			continue
 		}
+		heap.Push(&this.queue, info)
 	}
-	this.lock.Unlock()
-	if myNextFreshDuration >= 0 {
-		this.myKeepFreshS_C.Tid = myNextFreshTid
-		this.myKeepFreshS_C.Channel.Reset(myNextFreshDuration)
-	} else {
-		this.myKeepFreshS_C.Tid = []string{}
-		this.myKeepFreshS_C.Channel.Reset(time.Second * 19999)
+	return fires
+}
+
+// 距离下一个任务执行还需等待的时间，没有任务时返回 false，调用方需持有 lock
+func (this *CronServer) nextWait(now time.Time) (time.Duration, bool) {
+	if len(this.queue) == 0 {
+		return 0, false
 	}
+	return this.queue[0].nextFreshTime.Sub(now), true
 }
+
+func (this *CronServer) fire(due []*cronInfo) {
+	for _, info := range due {
+		this.runTask(info.callbackInfo, info.runs)
+	}
+}
+
 func (this *CronServer) start() {
+	defer close(this.loopDone)
+	// 没有任务时也需要一个 timer，先停掉，等有任务后再 Reset
+	timer := this.clock.NewTimer(time.Hour)
+	timer.Stop()
 	for {
+		this.lock.Lock()
+		now := this.clock.Now()
+		due := this.popDue(now)
+		wait, ok := this.nextWait(now)
+		dirty := this.takeDirtyJobsLocked()
+		this.lock.Unlock()
+
+		this.fire(due)
+		this.persistJobs(dirty)
+
+		timer.Stop()
+		if ok {
+			timer.Reset(wait)
+		}
 		select {
-		case <-this.myKeepFreshS_C.Channel.C:
-			existList := []*cronInfo{}
-			this.lock.Lock()
-			for index := range this.myKeepFreshS_C.Tid {
-				v, ok := this.CronMap[this.myKeepFreshS_C.Tid[index]]
-				if ok {
-					existList = append(existList, v)
-				}
-			}
-			this.lock.Unlock()
-			this.keepFresh(existList)
+		case <-timer.C():
 		case <-this.CronInChannel:
-			this.updateNextFreshAndCronOutTime()
+		case <-this.stopCh:
+			timer.Stop()
+			return
 		}
 	}
 }
 
-// func (this *CronServer) Start() {
-// 	go this.start()
-// }
+// 通知调度协程重新计算下次唤醒时间，已经有待处理的通知时直接忽略
+func (this *CronServer) wakeup(tid string) {
+	select {
+	case this.CronInChannel <- tid:
+	default:
+	}
+}
+
 func (this *CronServer) CronOut(tid string) {
 	this.lock.Lock()
-	delete(this.CronMap, tid)
+	if info, ok := this.CronMap[tid]; ok {
+		if info.index >= 0 {
+			heap.Remove(&this.queue, info.index)
+		}
+		delete(this.CronMap, tid)
+		if info.callbackInfo.Singleton {
+			this.releaseLease(tid)
+		}
+		if info.job != nil {
+			this.dirtyJobs[tid] = nil
+		}
+	}
+	delete(this.runs, tid)
 	this.lock.Unlock()
+	this.wakeup(tid)
 }
 func (this *CronServer) CronIn(howOftenKeepFresh time.Duration, callBack *CallbackInfoStruct) {
-	now := time.Now()
-	a := now.Add(howOftenKeepFresh)
+	this.cronInSchedule(Every(howOftenKeepFresh), howOftenKeepFresh, callBack)
+}
+
+// 按 cron 表达式定时执行，表达式格式见 ParseCron
+func (this *CronServer) CronInSpec(spec string, callBack *CallbackInfoStruct) error {
+	return this.CronInSpecWithLocation(spec, time.Local, callBack)
+}
+
+// 与 CronInSpec 相同，loc 为表达式默认的时区
+func (this *CronServer) CronInSpecWithLocation(spec string, loc *time.Location, callBack *CallbackInfoStruct) error {
+	schedule, err := ParseCronInLocation(spec, loc)
+	if err != nil {
+		return err
+	}
+	this.CronInSchedule(schedule, callBack)
+	return nil
+}
+
+// 按自定义的 Schedule 执行
+func (this *CronServer) CronInSchedule(schedule Schedule, callBack *CallbackInfoStruct) {
+	this.cronInSchedule(schedule, 0, callBack)
+}
+
+func (this *CronServer) cronInSchedule(schedule Schedule, howOftenKeepFresh time.Duration, callBack *CallbackInfoStruct) {
+	this.lock.Lock()
+	this.addLocked(schedule, schedule.Next(this.clock.Now()), howOftenKeepFresh, callBack)
+	this.lock.Unlock()
+	this.wakeup(callBack.TaskId)
+}
+
+// 同一个 TaskId 重复加入时替换原有任务，first 为第一次执行的时间，调用方需持有 lock
+// 返回新加入的任务，不会执行时返回 nil
+func (this *CronServer) addLocked(schedule Schedule, first time.Time, howOftenKeepFresh time.Duration, callBack *CallbackInfoStruct) *cronInfo {
+	if old, ok := this.CronMap[callBack.TaskId]; ok {
+		if old.index >= 0 {
+			heap.Remove(&this.queue, old.index)
+		}
+		if old.job != nil {
+			this.dirtyJobs[callBack.TaskId] = nil
+		}
+	}
+	delete(this.CronMap, callBack.TaskId)
+
+	if callBack.Singleton && this.locker == nil {
+		log.Errorf("[crontable] 任务 %s 为 Singleton，但没有通过 WithLocker 配置租约锁，每个副本都会执行", callBack.TaskId)
+	}
+	if first.IsZero() {
+		return nil
+	}
+	this.seq++
 	info := &cronInfo{
-		nextFreshTime:     a,
 		HowOftenKeepFresh: howOftenKeepFresh,
+		schedule:          schedule,
 		callbackInfo:      callBack,
+		runs:              this.taskRunsLocked(callBack.TaskId),
+		seq:               this.seq,
 	}
-	this.lock.Lock()
+	info.setNext(first)
 	this.CronMap[callBack.TaskId] = info
+	heap.Push(&this.queue, info)
+	return info
+}
+
+// 在指定时间执行一次，时间已经过去时尽快执行（受 Misfire 影响）
+func (this *CronServer) RunAt(at time.Time, callBack *CallbackInfoStruct) {
+	this.lock.Lock()
+	this.addLocked(onceSchedule{}, at, 0, callBack)
 	this.lock.Unlock()
-	this.CronInChannel <- callBack.TaskId
+	this.wakeup(callBack.TaskId)
 }
 
 //过一阵子再开始定时执行某个任务
 func (this *CronServer) CronInAfterWait(howOftenKeepFresh time.Duration, afterWait time.Duration, callBack *CallbackInfoStruct) {
-	taskTemp := xid.New().String()
-	outAfterWait := func(ctx context.Context, tid string) error {
-		this.CronOut(tid)
-		go callBack.CallbackFunc(callBack.Context, callBack.TaskId)
-		this.CronIn(howOftenKeepFresh, callBack)
-		return nil
-	}
-	this.CronIn(afterWait, &CallbackInfoStruct{
-		CallbackFunc: outAfterWait,
-		Context:      context.Background(),
-		TaskId:       taskTemp,
-	})
-
+	this.lock.Lock()
+	this.addLocked(Every(howOftenKeepFresh), this.clock.Now().Add(afterWait), howOftenKeepFresh, callBack)
+	this.lock.Unlock()
+	this.wakeup(callBack.TaskId)
 }
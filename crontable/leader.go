@@ -0,0 +1,150 @@
+package crontable
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/xid"
+	"golang.org/x/net/context"
+
+	"github.com/DoOR-Team/goutils/log"
+)
+
+// 多副本之间的租约锁，Singleton 任务执行前需要先拿到租约
+type Locker interface {
+	// 获取或续约 key 的租约，租约不存在、已过期或本来就属于 owner 时成功，并把过期时间延长到 ttl 之后
+	Acquire(ctx context.Context, key, owner string, ttl time.Duration) (bool, error)
+	// 释放 owner 持有的租约，不属于 owner 时忽略
+	Release(ctx context.Context, key, owner string) error
+}
+
+const defaultLeaseTTL = 30 * time.Second
+
+// 设置 Singleton 任务使用的租约锁，未设置时 Singleton 不生效，加入 Singleton 任务时会打印错误日志
+func WithLocker(locker Locker) CronOption {
+	return func(options *cronOptions) {
+		options.locker = locker
+	}
+}
+
+// 租约时长，持有租约的副本异常退出后，其他副本最晚在 ttl 之后接手
+// 执行期间每 ttl/3 续约一次，ttl 需要大于副本之间的时钟偏差
+func WithLeaseTTL(ttl time.Duration) CronOption {
+	return func(options *cronOptions) {
+		options.leaseTTL = ttl
+	}
+}
+
+// 当前副本的标识，默认为 hostname 加随机后缀
+func WithOwner(owner string) CronOption {
+	return func(options *cronOptions) {
+		options.owner = owner
+	}
+}
+
+func defaultOwner() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%s", host, xid.New().String())
+}
+
+func leaseKey(tid string) string {
+	return "crontable:" + tid
+}
+
+// 尝试成为任务的执行者，没有配置 Locker 时直接返回 true（加入任务时已经打印过错误日志）
+func (this *CronServer) acquireLease(tid string) (bool, error) {
+	if this.locker == nil {
+		return true, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), this.leaseTTL/3)
+	defer cancel()
+	return this.locker.Acquire(ctx, leaseKey(tid), this.owner, this.leaseTTL)
+}
+
+// 执行期间定期续约，返回的函数用于停止续约
+func (this *CronServer) keepLease(tid string) func() {
+	if this.locker == nil {
+		return func() {}
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		timer := this.clock.NewTimer(this.leaseTTL / 3)
+		defer timer.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-timer.C():
+			}
+			if ok, err := this.acquireLease(tid); err != nil || !ok {
+				log.Warnf("[crontable] 任务 %s 续约失败: ok=%v err=%v", tid, ok, err)
+			}
+			timer.Reset(this.leaseTTL / 3)
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+// CronOut 时主动释放租约，让其他副本尽快接手
+func (this *CronServer) releaseLease(tid string) {
+	if this.locker == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), this.leaseTTL/3)
+		defer cancel()
+		if err := this.locker.Release(ctx, leaseKey(tid), this.owner); err != nil {
+			log.Warnf("[crontable] 任务 %s 释放租约失败: %v", tid, err)
+		}
+	}()
+}
+
+type memoryLease struct {
+	owner    string
+	expireAt time.Time
+}
+
+// 进程内的租约锁，多个 CronServer 共用同一个实例时效果与 GormLocker 相同，主要用于测试
+type MemoryLocker struct {
+	mu     sync.Mutex
+	clock  Clock
+	leases map[string]memoryLease
+}
+
+func NewMemoryLocker() *MemoryLocker {
+	return NewMemoryLockerWithClock(realClock{})
+}
+
+func NewMemoryLockerWithClock(clock Clock) *MemoryLocker {
+	return &MemoryLocker{
+		clock:  clock,
+		leases: make(map[string]memoryLease),
+	}
+}
+
+func (l *MemoryLocker) Acquire(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.clock.Now()
+	if lease, ok := l.leases[key]; ok && lease.owner != owner && lease.expireAt.After(now) {
+		return false, nil
+	}
+	l.leases[key] = memoryLease{owner: owner, expireAt: now.Add(ttl)}
+	return true, nil
+}
+
+func (l *MemoryLocker) Release(ctx context.Context, key, owner string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if lease, ok := l.leases[key]; ok && lease.owner == owner {
+		delete(l.leases, key)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package crontable
+
+import (
+	"time"
+
+	"github.com/DoOR-Team/gorm"
+	"golang.org/x/net/context"
+)
+
+// 租约表，过期时间使用数据库时间，避免各副本时钟不一致
+type CronLease struct {
+	Name     string    `gorm:"primary_key;type:varchar(191)"`
+	Owner    string    `gorm:"type:varchar(191)"`
+	ExpireAt time.Time `gorm:"type:datetime(6)"`
+}
+
+func (CronLease) TableName() string {
+	return "cron_leases"
+}
+
+// 基于 MySQL 租约表的 Locker，db 一般为 db.GetMySqlDBFromVipper() 的返回值
+type GormLocker struct {
+	db *gorm.DB
+}
+
+// 会自动创建 cron_leases 表
+func NewGormLocker(db *gorm.DB) (*GormLocker, error) {
+	if err := db.AutoMigrate(&CronLease{}).Error; err != nil {
+		return nil, err
+	}
+	return &GormLocker{db: db}, nil
+}
+
+func (l *GormLocker) Acquire(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	// 记录不存在时插入；已过期或属于自己时抢占/续约，否则保持不变
+	// MySQL 按顺序执行赋值，expire_at 的判断里 owner 已经是更新后的值，两列的结果一致
+	err := l.db.Exec(`INSERT INTO cron_leases (name, owner, expire_at)
+VALUES (?, ?, NOW(6) + INTERVAL ? MICROSECOND)
+ON DUPLICATE KEY UPDATE
+owner = IF(expire_at < NOW(6) OR owner = VALUES(owner), VALUES(owner), owner),
+expire_at = IF(owner = VALUES(owner), VALUES(expire_at), expire_at)`,
+		key, owner, ttl.Microseconds()).Error
+	if err != nil {
+		return false, err
+	}
+
+	var current string
+	if err := l.db.Raw("SELECT owner FROM cron_leases WHERE name = ?", key).Row().Scan(&current); err != nil {
+		return false, err
+	}
+	return current == owner, nil
+}
+
+func (l *GormLocker) Release(ctx context.Context, key, owner string) error {
+	return l.db.Exec("DELETE FROM cron_leases WHERE name = ? AND owner = ?", key, owner).Error
+}
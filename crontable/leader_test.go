@@ -0,0 +1,126 @@
+package crontable
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestMemoryLocker(t *testing.T) {
+	clock := newFakeClock()
+	l := NewMemoryLockerWithClock(clock)
+	ctx := context.Background()
+	acquire := func(owner string, expect bool) {
+		t.Helper()
+		ok, err := l.Acquire(ctx, "job", owner, 3*time.Second)
+		if err != nil || ok != expect {
+			t.Fatalf("%s acquire: %v %v, expect %v", owner, ok, err, expect)
+		}
+	}
+
+	acquire("a", true)
+	acquire("b", false)
+	clock.Advance(2 * time.Second)
+	// 续约后从当前时间重新计算过期时间
+	acquire("a", true)
+	clock.Advance(2 * time.Second)
+	acquire("b", false)
+	clock.Advance(time.Second)
+	acquire("b", true)
+	acquire("a", false)
+
+	// 不属于自己的租约不会被释放
+	l.Release(ctx, "job", "a")
+	acquire("a", false)
+	l.Release(ctx, "job", "b")
+	acquire("a", true)
+}
+
+func TestSingletonFailover(t *testing.T) {
+	clock := newFakeClock()
+	locker := NewMemoryLockerWithClock(clock)
+	servers := []*CronServer{}
+	for _, owner := range []string{"a", "b"} {
+		c := newCron(WithClock(clock), WithLocker(locker), WithLeaseTTL(3*time.Second), WithOwner(owner))
+		c.CronIn(time.Second, &CallbackInfoStruct{
+			CallbackFunc: func(ctx context.Context, tid string) error { return nil },
+			Context:      context.Background(),
+			TaskId:       "job",
+			Singleton:    true,
+		})
+		servers = append(servers, c)
+	}
+
+	clock.Advance(time.Second)
+	for _, c := range servers {
+		c.fire(c.popDue(clock.Now()))
+	}
+	leader, follower := -1, -1
+	for i, c := range servers {
+		waitRun(t, c, "job", clock.Now())
+		switch c.History("job")[0].Outcome {
+		case OutcomeSuccess:
+			leader = i
+		case OutcomeNotLeader:
+			follower = i
+		}
+	}
+	if leader < 0 || follower < 0 {
+		t.Fatalf("leader %d follower %d", leader, follower)
+	}
+
+	// leader 不再执行，租约过期前 follower 仍然拿不到
+	for i := 0; i < 3; i++ {
+		clock.Advance(time.Second)
+		c := servers[follower]
+		c.fire(c.popDue(clock.Now()))
+		waitRun(t, c, "job", clock.Now())
+	}
+	if got := outcomes(servers[follower].History("job")); got != "not_leader,not_leader,not_leader,success" {
+		t.Fatalf("follower outcomes %s", got)
+	}
+}
+
+func TestSingletonRenew(t *testing.T) {
+	clock := newFakeClock()
+	locker := NewMemoryLockerWithClock(clock)
+	c := newCron(WithClock(clock), WithLocker(locker), WithLeaseTTL(3*time.Second), WithOwner("a"))
+	release := make(chan struct{})
+	c.CronIn(time.Second, &CallbackInfoStruct{
+		CallbackFunc: func(ctx context.Context, tid string) error {
+			<-release
+			return nil
+		},
+		Context:   context.Background(),
+		TaskId:    "job",
+		Singleton: true,
+	})
+
+	clock.Advance(time.Second)
+	c.fire(c.popDue(clock.Now()))
+	// 执行时间超过 ttl，期间每秒续约一次
+	for i := 0; i < 5; i++ {
+		clock.waitTimer(t, clock.Now().Add(time.Second))
+		clock.Advance(time.Second)
+	}
+	clock.waitTimer(t, clock.Now().Add(time.Second))
+	if ok, _ := locker.Acquire(context.Background(), leaseKey("job"), "b", time.Second); ok {
+		t.Fatal("lease lost while running")
+	}
+	close(release)
+
+	// CronOut 后租约被释放
+	c.CronOut("job")
+	deadline := time.After(time.Second)
+	for {
+		if ok, _ := locker.Acquire(context.Background(), leaseKey("job"), "b", time.Second); ok {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("lease not released")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
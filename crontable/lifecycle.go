@@ -0,0 +1,50 @@
+package crontable
+
+import (
+	"golang.org/x/net/context"
+)
+
+// NewCron 时以 name 注册为 waitgroup 的 Server 模块，进程退出时自动 Stop
+func WithWaitGroupMod(name string) CronOption {
+	return func(options *cronOptions) {
+		options.modName = name
+	}
+}
+
+// 停止调度并等待正在执行的任务结束，ctx 结束时不再等待并返回 ctx.Err()
+// Stop 之后任务不会再被触发，重复调用只会等待
+func (this *CronServer) Stop(ctx context.Context) error {
+	this.stopOnce.Do(func() {
+		close(this.stopCh)
+	})
+	if this.started {
+		select {
+		case <-this.loopDone:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		this.jobs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 实现 waitgroup.Server，阻塞到 Stop 为止
+func (this *CronServer) Serve() error {
+	<-this.stopCh
+	return nil
+}
+
+// 实现 waitgroup.Server，超时由 waitgroup 的关闭超时控制
+func (this *CronServer) Close() error {
+	return this.Stop(context.Background())
+}
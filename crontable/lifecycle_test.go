@@ -0,0 +1,185 @@
+package crontable
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/DoOR-Team/goutils/waitgroup"
+)
+
+var _ waitgroup.Server = (*CronServer)(nil)
+
+func TestRunAt(t *testing.T) {
+	clock := newFakeClock()
+	c := newCron(WithClock(clock))
+
+	c.RunAt(clock.Now().Add(2*time.Second), noopCallback("later"))
+	// 已经过去的时间在下一轮执行
+	c.RunAt(clock.Now().Add(-time.Second), noopCallback("past"))
+
+	if got := dueIds(c.popDue(clock.Now())); fmt.Sprint(got) != "[past]" {
+		t.Fatalf("got %v", got)
+	}
+	clock.Advance(time.Second)
+	if got := c.popDue(clock.Now()); len(got) != 0 {
+		t.Fatalf("fired early: %v", dueIds(got))
+	}
+	clock.Advance(time.Second)
+	if got := dueIds(c.popDue(clock.Now())); fmt.Sprint(got) != "[later]" {
+		t.Fatalf("got %v", got)
+	}
+	if len(c.CronMap) != 0 || len(c.queue) != 0 {
+		t.Fatalf("one-shot tasks left: %d %d", len(c.CronMap), len(c.queue))
+	}
+}
+
+func TestCronInAfterWait(t *testing.T) {
+	clock := newFakeClock()
+	c := newCron(WithClock(clock))
+	start := clock.Now()
+
+	c.CronInAfterWait(5*time.Second, 2*time.Second, noopCallback("job"))
+	fired := []time.Duration{}
+	for i := 0; i < 12; i++ {
+		clock.Advance(time.Second)
+		if len(c.popDue(clock.Now())) > 0 {
+			fired = append(fired, clock.Now().Sub(start))
+		}
+	}
+	if fmt.Sprint(fired) != "[2s 7s 12s]" {
+		t.Fatalf("fired at %v", fired)
+	}
+}
+
+func TestMisfire(t *testing.T) {
+	cases := []struct {
+		policy   MisfirePolicy
+		fires    int
+		outcomes string
+	}{
+		{MisfireFireOnce, 1, ""},
+		{MisfireFireAll, 5, ""},
+		{MisfireSkip, 0, "misfired"},
+	}
+	for _, cs := range cases {
+		clock := newFakeClock()
+		c := newCron(WithClock(clock))
+		start := clock.Now()
+		cb := noopCallback("job")
+		cb.Misfire = cs.policy
+		c.CronIn(time.Second, cb)
+
+		// 暂停了 5 秒
+		clock.Advance(5*time.Second + 300*time.Millisecond)
+		if got := len(c.popDue(clock.Now())); got != cs.fires {
+			t.Fatalf("policy %d: fired %d, expect %d", cs.policy, got, cs.fires)
+		}
+		if got := outcomes(c.History("job")); got != cs.outcomes {
+			t.Fatalf("policy %d: outcomes %q", cs.policy, got)
+		}
+		// 之后按原来的节奏执行
+		if next := c.CronMap["job"].nextFreshTime; !next.Equal(start.Add(6 * time.Second)) {
+			t.Fatalf("policy %d: next fire %v", cs.policy, next)
+		}
+		clock.Advance(700 * time.Millisecond)
+		if got := len(c.popDue(clock.Now())); got != 1 {
+			t.Fatalf("policy %d: fired %d on time", cs.policy, got)
+		}
+	}
+}
+
+func TestMisfireThreshold(t *testing.T) {
+	clock := newFakeClock()
+	c := newCron(WithClock(clock), WithMisfireThreshold(time.Second))
+	cb := noopCallback("job")
+	cb.Misfire = MisfireSkip
+	c.CronIn(10*time.Second, cb)
+
+	// 晚了不到 1 秒，不算错过
+	clock.Advance(10*time.Second + 500*time.Millisecond)
+	if got := len(c.popDue(clock.Now())); got != 1 {
+		t.Fatalf("fired %d", got)
+	}
+	clock.Advance(11 * time.Second)
+	if got := len(c.popDue(clock.Now())); got != 0 {
+		t.Fatalf("fired %d", got)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	clock := newFakeClock()
+	c := newCron(WithClock(clock))
+	start := clock.Now()
+	cb := noopCallback("job")
+	cb.Jitter = 500 * time.Millisecond
+	cb.Misfire = MisfireSkip
+	c.CronIn(10*time.Second, cb)
+
+	for i := 1; i <= 20; i++ {
+		info := c.CronMap["job"]
+		scheduled := start.Add(time.Duration(i) * 10 * time.Second)
+		if !info.scheduledTime.Equal(scheduled) {
+			t.Fatalf("round %d: scheduled %v", i, info.scheduledTime)
+		}
+		if delay := info.nextFreshTime.Sub(scheduled); delay < 0 || delay >= cb.Jitter {
+			t.Fatalf("round %d: jitter %v", i, delay)
+		}
+		clock.Advance(info.nextFreshTime.Sub(clock.Now()))
+		// 推迟执行不算错过
+		if got := len(c.popDue(clock.Now())); got != 1 {
+			t.Fatalf("round %d: fired %d", i, got)
+		}
+	}
+}
+
+func TestStop(t *testing.T) {
+	clock := newFakeClock()
+	c := newCron(WithClock(clock))
+	c.started = true
+	go c.start()
+	served := make(chan error, 1)
+	go func() { served <- c.Serve() }()
+
+	release := make(chan struct{})
+	fired := make(chan struct{}, 10)
+	c.CronIn(time.Second, &CallbackInfoStruct{
+		CallbackFunc: func(ctx context.Context, tid string) error {
+			fired <- struct{}{}
+			<-release
+			return nil
+		},
+		Context: context.Background(),
+		TaskId:  "job",
+	})
+	clock.waitTimer(t, clock.Now().Add(time.Second))
+	clock.Advance(time.Second)
+	<-fired
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := c.Stop(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("stop with running job: %v", err)
+	}
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Serve not returned after Stop")
+	}
+
+	close(release)
+	if err := c.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(5 * time.Second)
+	select {
+	case <-fired:
+		t.Fatal("fired after Stop")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
@@ -0,0 +1,73 @@
+package crontable
+
+import (
+	"math/rand"
+	"time"
+)
+
+// 进程暂停或者调度落后，错过了执行时间时的处理方式
+type MisfirePolicy int
+
+const (
+	// 只补执行一次（默认行为）
+	MisfireFireOnce MisfirePolicy = iota
+	// 错过几次补执行几次，最多 maxMisfireRuns 次
+	MisfireFireAll
+	// 不补执行，等下一次
+	MisfireSkip
+)
+
+const (
+	defaultMisfireThreshold = time.Second
+	maxMisfireRuns          = 100
+)
+
+// 实际执行时间比计划晚多少算错过，默认 1 秒
+func WithMisfireThreshold(threshold time.Duration) CronOption {
+	return func(options *cronOptions) {
+		options.misfireThreshold = threshold
+	}
+}
+
+// RunAt 使用，执行一次后不再执行
+type onceSchedule struct{}
+
+func (onceSchedule) Next(t time.Time) time.Time {
+	return time.Time{}
+}
+
+func (info *cronInfo) setNext(next time.Time) {
+	info.scheduledTime = next
+	info.nextFreshTime = next
+	if jitter := info.callbackInfo.Jitter; jitter > 0 && !next.IsZero() {
+		info.nextFreshTime = next.Add(time.Duration(rand.Int63n(int64(jitter))))
+	}
+}
+
+// 按计划时间推算下一次执行时间，返回本轮需要执行的次数
+func (this *CronServer) reschedule(info *cronInfo, now time.Time) int {
+	// 去掉 Jitter 的影响，按计划时间判断错过了几次
+	nominalNow := now.Add(-info.nextFreshTime.Sub(info.scheduledTime))
+	missed := 1
+	next := info.schedule.Next(info.scheduledTime)
+	for !next.IsZero() && !next.After(nominalNow) && missed < maxMisfireRuns {
+		missed++
+		next = info.schedule.Next(next)
+	}
+	if !next.IsZero() && !next.After(nominalNow) {
+		next = info.schedule.Next(nominalNow)
+	}
+	late := missed > 1 || nominalNow.Sub(info.scheduledTime) > this.misfireThreshold
+	info.setNext(next)
+
+	if !late {
+		return 1
+	}
+	switch info.callbackInfo.Misfire {
+	case MisfireFireAll:
+		return missed
+	case MisfireSkip:
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,212 @@
+package crontable
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/DoOR-Team/goutils/alert"
+	"github.com/DoOR-Team/goutils/log"
+	"github.com/DoOR-Team/goutils/trace"
+)
+
+// 上一次执行还没结束时，新一轮到期的处理方式
+type OverlapPolicy int
+
+const (
+	// 允许同一个任务并发执行（默认行为）
+	OverlapAllow OverlapPolicy = iota
+	// 跳过本轮，记录为 OutcomeSkipped
+	OverlapSkip
+	// 排队，等上一次结束后依次执行
+	OverlapQueue
+)
+
+type RunOutcome int
+
+const (
+	OutcomeSuccess RunOutcome = iota
+	OutcomeFailed
+	OutcomePanic
+	OutcomeSkipped
+	// Singleton 任务没有拿到租约，由其他副本执行
+	OutcomeNotLeader
+	// 执行时间已经错过，按 MisfireSkip 跳过
+	OutcomeMisfired
+)
+
+var runOutcomeNames = map[RunOutcome]string{
+	OutcomeSuccess:   "success",
+	OutcomeFailed:    "failed",
+	OutcomePanic:     "panic",
+	OutcomeSkipped:   "skipped",
+	OutcomeNotLeader: "not_leader",
+	OutcomeMisfired:  "misfired",
+}
+
+func (o RunOutcome) String() string {
+	if name, ok := runOutcomeNames[o]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+func (o RunOutcome) MarshalText() ([]byte, error) {
+	return []byte(o.String()), nil
+}
+
+// 一次执行的记录
+type RunRecord struct {
+	Start    time.Time
+	Duration time.Duration
+	Err      error
+	Outcome  RunOutcome
+}
+
+const defaultHistorySize = 10
+
+// 每个任务保留最近几次执行记录
+func WithHistorySize(size int) CronOption {
+	return func(options *cronOptions) {
+		options.historySize = size
+	}
+}
+
+var panicAlert = func(tid string, text string) {
+	go func() {
+		if err := alert.AlertDingMsgWithConfig("crontable", text, alert.AutoFire()); err != nil {
+			log.Error(err)
+		}
+	}()
+}
+
+// 单个任务的执行状态，同一个 TaskId 重新加入时沿用
+type taskRuns struct {
+	mu      sync.Mutex
+	running int
+	queued  int
+	// 环形缓冲，next 为下一条记录写入的位置
+	history []RunRecord
+	next    int
+	full    bool
+}
+
+func newTaskRuns(size int) *taskRuns {
+	if size < 0 {
+		size = 0
+	}
+	return &taskRuns{history: make([]RunRecord, size)}
+}
+
+func (r *taskRuns) record(rec RunRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.history) == 0 {
+		return
+	}
+	r.history[r.next] = rec
+	r.next = (r.next + 1) % len(r.history)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+func (r *taskRuns) records() []RunRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]RunRecord{}, r.history[:r.next]...)
+	}
+	return append(append([]RunRecord{}, r.history[r.next:]...), r.history[:r.next]...)
+}
+
+// 最近的执行记录，按开始时间从早到晚排列
+func (this *CronServer) History(tid string) []RunRecord {
+	this.lock.Lock()
+	runs, ok := this.runs[tid]
+	this.lock.Unlock()
+	if !ok {
+		return nil
+	}
+	return runs.records()
+}
+
+// 调用方需持有 lock
+func (this *CronServer) taskRunsLocked(tid string) *taskRuns {
+	runs, ok := this.runs[tid]
+	if !ok {
+		runs = newTaskRuns(this.historySize)
+		this.runs[tid] = runs
+	}
+	return runs
+}
+
+// 按任务的 OverlapPolicy 执行一次回调
+func (this *CronServer) runTask(callBack *CallbackInfoStruct, runs *taskRuns) {
+	runs.mu.Lock()
+	if runs.running > 0 {
+		switch callBack.Overlap {
+		case OverlapSkip:
+			runs.mu.Unlock()
+			runs.record(RunRecord{Start: this.clock.Now(), Outcome: OutcomeSkipped})
+			return
+		case OverlapQueue:
+			runs.queued++
+			runs.mu.Unlock()
+			return
+		}
+	}
+	runs.running++
+	runs.mu.Unlock()
+
+	this.jobs.Add(1)
+	go func() {
+		defer this.jobs.Done()
+		for {
+			runs.record(this.call(callBack))
+
+			runs.mu.Lock()
+			if runs.queued > 0 {
+				runs.queued--
+				runs.mu.Unlock()
+				continue
+			}
+			runs.running--
+			runs.mu.Unlock()
+			return
+		}
+	}()
+}
+
+func (this *CronServer) call(callBack *CallbackInfoStruct) (rec RunRecord) {
+	rec.Start = this.clock.Now()
+	if callBack.Singleton {
+		leader, err := this.acquireLease(callBack.TaskId)
+		if err != nil {
+			rec.Err = err
+			rec.Outcome = OutcomeFailed
+			return rec
+		}
+		if !leader {
+			rec.Outcome = OutcomeNotLeader
+			return rec
+		}
+		defer this.keepLease(callBack.TaskId)()
+	}
+	defer func() {
+		rec.Duration = this.clock.Now().Sub(rec.Start)
+		if perr := recover(); perr != nil {
+			rec.Err = fmt.Errorf("panic: %v", perr)
+			rec.Outcome = OutcomePanic
+			msg := fmt.Sprintf("定时任务 %s panic: %v\n%s", callBack.TaskId, perr, string(trace.PanicTrace(10)))
+			log.Error(msg)
+			panicAlert(callBack.TaskId, msg)
+		}
+	}()
+
+	rec.Err = callBack.CallbackFunc(callBack.Context, callBack.TaskId)
+	if rec.Err != nil {
+		rec.Outcome = OutcomeFailed
+	}
+	return rec
+}
@@ -0,0 +1,184 @@
+package crontable
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// 等待任务的执行记录达到 n 条
+func waitHistory(t *testing.T, c *CronServer, tid string, n int) []RunRecord {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for {
+		if records := c.History(tid); len(records) >= n {
+			return records
+		}
+		select {
+		case <-timeout:
+			t.Fatalf("task %s history %v, expect %d records", tid, c.History(tid), n)
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+// 等待开始时间为 start 的那次执行结束
+func waitRun(t *testing.T, c *CronServer, tid string, start time.Time) {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for {
+		for _, rec := range c.History(tid) {
+			if rec.Start.Equal(start) {
+				return
+			}
+		}
+		select {
+		case <-timeout:
+			t.Fatalf("task %s not run at %v", tid, start)
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func outcomes(records []RunRecord) string {
+	names := []string{}
+	for _, rec := range records {
+		names = append(names, rec.Outcome.String())
+	}
+	return strings.Join(names, ",")
+}
+
+func TestRunHistory(t *testing.T) {
+	alerts := make(chan string, 1)
+	origAlert := panicAlert
+	panicAlert = func(tid, text string) { alerts <- tid }
+	defer func() { panicAlert = origAlert }()
+
+	clock := newFakeClock()
+	c := newCron(WithClock(clock), WithHistorySize(3))
+
+	i := 0
+	c.CronIn(time.Second, &CallbackInfoStruct{
+		CallbackFunc: func(ctx context.Context, tid string) error {
+			i++
+			switch i {
+			case 2:
+				return errors.New("boom")
+			case 3:
+				panic("oops")
+			}
+			return nil
+		},
+		Context: context.Background(),
+		TaskId:  "job",
+	})
+
+	for n := 1; n <= 4; n++ {
+		clock.Advance(time.Second)
+		c.fire(c.popDue(clock.Now()))
+		waitRun(t, c, "job", clock.Now())
+	}
+
+	records := c.History("job")
+	// 只保留最近 3 次
+	if got := outcomes(records); got != "failed,panic,success" {
+		t.Fatalf("outcomes %s", got)
+	}
+	if records[0].Err == nil || records[0].Err.Error() != "boom" {
+		t.Fatalf("err %v", records[0].Err)
+	}
+	if records[1].Err == nil || !strings.Contains(records[1].Err.Error(), "oops") {
+		t.Fatalf("err %v", records[1].Err)
+	}
+	if !records[2].Start.Equal(clock.Now()) {
+		t.Fatalf("start %v", records[2].Start)
+	}
+	select {
+	case tid := <-alerts:
+		if tid != "job" {
+			t.Fatalf("alert for %s", tid)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("panic not alerted")
+	}
+
+	c.CronOut("job")
+	if records := c.History("job"); records != nil {
+		t.Fatalf("history after CronOut %v", records)
+	}
+}
+
+// 第一次执行会阻塞到 release 关闭
+func blockingTask(c *CronServer, tid string, overlap OverlapPolicy) (release chan struct{}, running func() int) {
+	release = make(chan struct{})
+	var mu sync.Mutex
+	cur, max := 0, 0
+	first := true
+	c.CronIn(time.Second, &CallbackInfoStruct{
+		CallbackFunc: func(ctx context.Context, tid string) error {
+			mu.Lock()
+			cur++
+			if cur > max {
+				max = cur
+			}
+			block := first
+			first = false
+			mu.Unlock()
+			if block {
+				<-release
+			}
+			mu.Lock()
+			cur--
+			mu.Unlock()
+			return nil
+		},
+		Context: context.Background(),
+		TaskId:  tid,
+		Overlap: overlap,
+	})
+	return release, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return max
+	}
+}
+
+func TestOverlapPolicy(t *testing.T) {
+	cases := []struct {
+		overlap  OverlapPolicy
+		outcomes string
+		max      int
+	}{
+		{OverlapAllow, "success,success,success", 2},
+		{OverlapSkip, "skipped,skipped,success", 1},
+		{OverlapQueue, "success,success,success", 1},
+	}
+	for _, cs := range cases {
+		clock := newFakeClock()
+		c := newCron(WithClock(clock))
+		release, maxRunning := blockingTask(c, "job", cs.overlap)
+
+		for n := 0; n < 3; n++ {
+			clock.Advance(time.Second)
+			c.fire(c.popDue(clock.Now()))
+		}
+		if cs.overlap == OverlapAllow {
+			// 后两次不阻塞，先于第一次结束
+			waitHistory(t, c, "job", 2)
+		}
+		close(release)
+
+		records := waitHistory(t, c, "job", 3)
+		if got := outcomes(records); got != cs.outcomes {
+			t.Fatalf("policy %d: outcomes %s, expect %s", cs.overlap, got, cs.outcomes)
+		}
+		// 允许并发时后两次之间也可能重叠
+		if got := maxRunning(); got < cs.max || cs.overlap != OverlapAllow && got != cs.max {
+			t.Fatalf("policy %d: max running %d, expect %d", cs.overlap, got, cs.max)
+		}
+	}
+}
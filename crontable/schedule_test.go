@@ -0,0 +1,81 @@
+package crontable
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronNext(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip(err)
+	}
+	// 2021-03-01 是周一
+	from := time.Date(2021, 3, 1, 1, 30, 0, 0, shanghai)
+
+	cases := []struct {
+		spec   string
+		expect time.Time
+	}{
+		{"0 2 * * *", time.Date(2021, 3, 1, 2, 0, 0, 0, shanghai)},
+		{"30 9 * * MON", time.Date(2021, 3, 1, 9, 30, 0, 0, shanghai)},
+		{"30 9 * * 1-5", time.Date(2021, 3, 1, 9, 30, 0, 0, shanghai)},
+		{"30 9 * * sun", time.Date(2021, 3, 7, 9, 30, 0, 0, shanghai)},
+		{"30 9 * * 7", time.Date(2021, 3, 7, 9, 30, 0, 0, shanghai)},
+		{"*/15 * * * *", time.Date(2021, 3, 1, 1, 45, 0, 0, shanghai)},
+		{"*/10 * * * * *", time.Date(2021, 3, 1, 1, 30, 10, 0, shanghai)},
+		{"0 0 1,15 * *", time.Date(2021, 3, 15, 0, 0, 0, 0, shanghai)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, shanghai)},
+		{"@daily", time.Date(2021, 3, 2, 0, 0, 0, 0, shanghai)},
+		{"@hourly", time.Date(2021, 3, 1, 2, 0, 0, 0, shanghai)},
+		{"@monthly", time.Date(2021, 4, 1, 0, 0, 0, 0, shanghai)},
+		{"@weekly", time.Date(2021, 3, 7, 0, 0, 0, 0, shanghai)},
+		{"@yearly", time.Date(2022, 1, 1, 0, 0, 0, 0, shanghai)},
+		{"@every 90m", time.Date(2021, 3, 1, 3, 0, 0, 0, shanghai)},
+		// 日期与星期都有限制时满足其一即可
+		{"0 0 13 * FRI", time.Date(2021, 3, 5, 0, 0, 0, 0, shanghai)},
+		// 表达式时区与 from 不同
+		{"CRON_TZ=UTC 0 0 * * *", time.Date(2021, 3, 1, 8, 0, 0, 0, shanghai)},
+	}
+	for _, c := range cases {
+		s, err := ParseCronInLocation(c.spec, shanghai)
+		if err != nil {
+			t.Fatalf("%s: %v", c.spec, err)
+		}
+		if got := s.Next(from); !got.Equal(c.expect) {
+			t.Errorf("%s: expect %v, got %v", c.spec, c.expect, got)
+		}
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"* * * * foo",
+		"@fortnightly",
+		"@every -1s",
+		"CRON_TZ=Mars/Olympus * * * * *",
+	} {
+		if _, err := ParseCron(spec); err == nil {
+			t.Errorf("%q: expect error", spec)
+		}
+	}
+}
+
+func TestCronNeverFires(t *testing.T) {
+	s, err := ParseCron("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := s.Next(time.Now()); !next.IsZero() {
+		t.Fatalf("expect zero time, got %v", next)
+	}
+}
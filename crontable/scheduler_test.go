@@ -0,0 +1,332 @@
+package crontable
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// 手动推进的时钟，Advance 时触发到期的 timer
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{clock: c, c: make(chan time.Time, 1)}
+	c.mu.Lock()
+	c.timers = append(c.timers, t)
+	c.mu.Unlock()
+	t.Reset(d)
+	return t
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for _, t := range c.timers {
+		t.fireLocked()
+	}
+}
+
+// 等待某个 timer 被设置到 deadline，说明调度协程已经处理完之前的变化
+func (c *fakeClock) waitTimer(t *testing.T, deadline time.Time) {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for {
+		c.mu.Lock()
+		for _, timer := range c.timers {
+			if timer.active && timer.deadline.Equal(deadline) {
+				c.mu.Unlock()
+				return
+			}
+		}
+		c.mu.Unlock()
+		select {
+		case <-timeout:
+			t.Fatalf("no timer armed for %v", deadline)
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+type fakeTimer struct {
+	clock    *fakeClock
+	c        chan time.Time
+	deadline time.Time
+	active   bool
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	active := t.active
+	t.active = false
+	return active
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	active := t.active
+	t.active = true
+	t.deadline = t.clock.now.Add(d)
+	t.fireLocked()
+	return active
+}
+
+func (t *fakeTimer) fireLocked() {
+	if !t.active || t.deadline.After(t.clock.now) {
+		return
+	}
+	t.active = false
+	select {
+	case t.c <- t.clock.now:
+	default:
+	}
+}
+
+func noopCallback(tid string) *CallbackInfoStruct {
+	return &CallbackInfoStruct{
+		CallbackFunc: func(ctx context.Context, tid string) error { return nil },
+		Context:      context.Background(),
+		TaskId:       tid,
+	}
+}
+
+func dueIds(due []*cronInfo) []string {
+	ids := []string{}
+	for _, info := range due {
+		ids = append(ids, info.callbackInfo.TaskId)
+	}
+	return ids
+}
+
+func TestSchedulerOrder(t *testing.T) {
+	clock := newFakeClock()
+	c := newCron(WithClock(clock))
+	start := clock.Now()
+
+	c.CronIn(3*time.Second, noopCallback("c"))
+	c.CronIn(time.Second, noopCallback("a"))
+	c.CronIn(2*time.Second, noopCallback("b"))
+	// 同时到期的任务按加入顺序执行
+	c.CronIn(time.Second, noopCallback("a2"))
+
+	expect := [][]string{
+		{"a", "a2"},
+		{"a", "b", "a2"},
+		{"c", "a", "a2"},
+		{"a", "b", "a2"},
+	}
+	for i, ids := range expect {
+		clock.Advance(time.Second)
+		got := dueIds(c.popDue(clock.Now()))
+		if fmt.Sprint(got) != fmt.Sprint(ids) {
+			t.Fatalf("tick %d: got %v, expect %v", i+1, got, ids)
+		}
+	}
+
+	wait, ok := c.nextWait(clock.Now())
+	if !ok || wait != time.Second {
+		t.Fatalf("next wait %v %v", wait, ok)
+	}
+	if next := c.CronMap["c"].nextFreshTime; !next.Equal(start.Add(6 * time.Second)) {
+		t.Fatalf("c next fire %v", next)
+	}
+}
+
+func TestSchedulerCronOutAndReplace(t *testing.T) {
+	clock := newFakeClock()
+	c := newCron(WithClock(clock))
+
+	c.CronIn(time.Second, noopCallback("a"))
+	c.CronIn(time.Second, noopCallback("b"))
+	c.CronIn(time.Second, noopCallback("c"))
+	c.CronOut("b")
+	c.CronOut("missing")
+	// 重复加入时替换原来的间隔
+	c.CronIn(5*time.Second, noopCallback("c"))
+
+	clock.Advance(time.Second)
+	if got := dueIds(c.popDue(clock.Now())); fmt.Sprint(got) != "[a]" {
+		t.Fatalf("got %v", got)
+	}
+	if len(c.queue) != 2 || len(c.CronMap) != 2 {
+		t.Fatalf("queue %d map %d", len(c.queue), len(c.CronMap))
+	}
+
+	clock.Advance(4 * time.Second)
+	if got := dueIds(c.popDue(clock.Now())); fmt.Sprint(got) != "[a c]" {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestSchedulerSpecAndNever(t *testing.T) {
+	clock := newFakeClock()
+	c := newCron(WithClock(clock))
+	start := clock.Now()
+
+	if err := c.CronInSpecWithLocation("*/10 * * * * *", time.UTC, noopCallback("spec")); err != nil {
+		t.Fatal(err)
+	}
+	yearly, err := ParseCronInLocation("@yearly", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.CronInSchedule(yearly, noopCallback("yearly"))
+	// Next 返回零值的任务不会被加入
+	c.CronInSchedule(scheduleFunc(func(time.Time) time.Time { return time.Time{} }), noopCallback("never"))
+	if _, ok := c.CronMap["never"]; ok {
+		t.Fatal("never task should not be scheduled")
+	}
+
+	clock.Advance(9 * time.Second)
+	if got := c.popDue(clock.Now()); len(got) != 0 {
+		t.Fatalf("fired early: %v", dueIds(got))
+	}
+	clock.Advance(time.Second)
+	if got := dueIds(c.popDue(clock.Now())); fmt.Sprint(got) != "[spec]" {
+		t.Fatalf("got %v", got)
+	}
+	if next := c.CronMap["spec"].nextFreshTime; !next.Equal(start.Add(20 * time.Second)) {
+		t.Fatalf("spec next fire %v", next)
+	}
+	if next := c.CronMap["yearly"].nextFreshTime; !next.Equal(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("yearly next fire %v", next)
+	}
+}
+
+type scheduleFunc func(time.Time) time.Time
+
+func (f scheduleFunc) Next(t time.Time) time.Time {
+	return f(t)
+}
+
+func TestSchedulerManyTasks(t *testing.T) {
+	clock := newFakeClock()
+	c := newCron(WithClock(clock))
+
+	const n = 5000
+	for i := 0; i < n; i++ {
+		c.CronIn(time.Duration(i%10+1)*time.Second, noopCallback(fmt.Sprint(i)))
+	}
+	for i := 0; i < n; i += 2 {
+		c.CronOut(fmt.Sprint(i))
+	}
+
+	counts := map[string]int{}
+	for i := 0; i < 60; i++ {
+		clock.Advance(time.Second)
+		for _, id := range dueIds(c.popDue(clock.Now())) {
+			counts[id]++
+		}
+	}
+	for i := 0; i < n; i++ {
+		id := fmt.Sprint(i)
+		expect := 0
+		if i%2 == 1 {
+			expect = 60 / (i%10 + 1)
+		}
+		if counts[id] != expect {
+			t.Fatalf("task %s fired %d times, expect %d", id, counts[id], expect)
+		}
+	}
+}
+
+func TestSchedulerLoop(t *testing.T) {
+	clock := newFakeClock()
+	c := newCron(WithClock(clock))
+	go c.start()
+	start := clock.Now()
+
+	var mu sync.Mutex
+	counts := map[string]int{}
+	fired := make(chan struct{}, 100)
+	callback := func(tid string) *CallbackInfoStruct {
+		return &CallbackInfoStruct{
+			CallbackFunc: func(ctx context.Context, tid string) error {
+				mu.Lock()
+				counts[tid]++
+				mu.Unlock()
+				fired <- struct{}{}
+				return nil
+			},
+			Context: context.Background(),
+			TaskId:  tid,
+		}
+	}
+
+	c.CronIn(time.Second, callback("a"))
+	c.CronIn(3*time.Second, callback("b"))
+	for i := 1; i <= 6; i++ {
+		clock.waitTimer(t, start.Add(time.Duration(i)*time.Second))
+		clock.Advance(time.Second)
+	}
+	for i := 0; i < 8; i++ {
+		select {
+		case <-fired:
+		case <-time.After(time.Second):
+			t.Fatal("callback not fired")
+		}
+	}
+	select {
+	case <-fired:
+		t.Fatal("unexpected extra fire")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if counts["a"] != 6 || counts["b"] != 2 {
+		t.Fatalf("counts %v", counts)
+	}
+}
+
+func BenchmarkCronIn(b *testing.B) {
+	c := newCron(WithClock(newFakeClock()))
+	for i := 0; i < 10000; i++ {
+		c.CronIn(time.Duration(i%100+1)*time.Second, noopCallback(fmt.Sprint("base", i)))
+	}
+	ids := make([]string, b.N)
+	for i := range ids {
+		ids[i] = fmt.Sprint(i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.CronIn(time.Duration(i%100+1)*time.Second, noopCallback(ids[i]))
+		c.CronOut(ids[i])
+	}
+}
+
+func BenchmarkPopDue(b *testing.B) {
+	clock := newFakeClock()
+	c := newCron(WithClock(clock))
+	for i := 0; i < 10000; i++ {
+		c.CronIn(time.Duration(i%100+1)*time.Second, noopCallback(fmt.Sprint(i)))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		clock.Advance(time.Second)
+		c.popDue(clock.Now())
+	}
+}
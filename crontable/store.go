@@ -0,0 +1,227 @@
+package crontable
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+	"golang.org/x/net/context"
+
+	"github.com/DoOR-Team/goutils/log"
+)
+
+// 需要持久化的任务，重启后通过 LoadJobs 恢复
+type Job struct {
+	TaskId string `gorm:"primary_key;type:varchar(191)"`
+	// cron 表达式，格式见 ParseCron，为空表示只在 NextFire 执行一次
+	Spec string `gorm:"type:varchar(255)"`
+	// 通过 RegisterHandler 注册的回调名
+	Handler string `gorm:"type:varchar(191)"`
+	Payload []byte `gorm:"type:blob"`
+	// 下一次计划执行的时间，AddJob 时为零值则按 Spec 计算
+	NextFire time.Time `gorm:"type:datetime(6)"`
+	// 重启后 NextFire 已经过去时的处理方式
+	Misfire MisfirePolicy
+}
+
+func (Job) TableName() string {
+	return "cron_jobs"
+}
+
+// 持久化的任务回调，payload 为 Job.Payload
+type Handler func(ctx context.Context, tid string, payload []byte) error
+
+// 任务的存储，Save 为覆盖写
+type JobStore interface {
+	Save(ctx context.Context, job *Job) error
+	Delete(ctx context.Context, tid string) error
+	List(ctx context.Context) ([]*Job, error)
+}
+
+const jobStoreTimeout = 3 * time.Second
+
+// 设置任务存储，默认保存在内存中
+func WithJobStore(store JobStore) CronOption {
+	return func(options *cronOptions) {
+		options.jobStore = store
+	}
+}
+
+// 注册回调，需要在 AddJob、LoadJobs 之前完成
+func (this *CronServer) RegisterHandler(name string, handler Handler) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.handlers[name] = handler
+}
+
+// 保存并开始执行任务，同一个 TaskId 会覆盖原来的任务
+func (this *CronServer) AddJob(ctx context.Context, job Job) error {
+	schedule, first, callBack, err := this.prepareJob(&job)
+	if err != nil {
+		return err
+	}
+	job.NextFire = first
+	if err := this.jobStore.Save(ctx, &job); err != nil {
+		return err
+	}
+	this.scheduleJob(&job, schedule, callBack)
+	return nil
+}
+
+// 停止并删除任务
+func (this *CronServer) RemoveJob(ctx context.Context, tid string) error {
+	this.CronOut(tid)
+	return this.jobStore.Delete(ctx, tid)
+}
+
+// 从存储中恢复所有任务，NextFire 已经过去的任务按 Misfire 处理
+// 单个任务恢复失败不影响其他任务，返回所有的错误
+func (this *CronServer) LoadJobs(ctx context.Context) error {
+	jobs, err := this.jobStore.List(ctx)
+	if err != nil {
+		return err
+	}
+	var errs error
+	for _, job := range jobs {
+		schedule, first, callBack, err := this.prepareJob(job)
+		if err != nil {
+			errs = multierr.Append(errs, err)
+			continue
+		}
+		job.NextFire = first
+		this.scheduleJob(job, schedule, callBack)
+	}
+	return errs
+}
+
+func (this *CronServer) prepareJob(job *Job) (Schedule, time.Time, *CallbackInfoStruct, error) {
+	this.lock.Lock()
+	handler, ok := this.handlers[job.Handler]
+	this.lock.Unlock()
+	if !ok {
+		return nil, time.Time{}, nil, fmt.Errorf("crontable: job %s uses unknown handler %q", job.TaskId, job.Handler)
+	}
+
+	var schedule Schedule = onceSchedule{}
+	first := job.NextFire
+	if job.Spec != "" {
+		var err error
+		if schedule, err = ParseCron(job.Spec); err != nil {
+			return nil, time.Time{}, nil, fmt.Errorf("crontable: job %s: %v", job.TaskId, err)
+		}
+		if first.IsZero() {
+			first = schedule.Next(this.clock.Now())
+		}
+	}
+	if first.IsZero() {
+		return nil, time.Time{}, nil, fmt.Errorf("crontable: job %s will never fire", job.TaskId)
+	}
+
+	payload := job.Payload
+	callBack := &CallbackInfoStruct{
+		CallbackFunc: func(ctx context.Context, tid string) error {
+			return handler(ctx, tid, payload)
+		},
+		Context: context.Background(),
+		TaskId:  job.TaskId,
+		Misfire: job.Misfire,
+	}
+	return schedule, first, callBack, nil
+}
+
+func (this *CronServer) scheduleJob(job *Job, schedule Schedule, callBack *CallbackInfoStruct) {
+	this.lock.Lock()
+	if info := this.addLocked(schedule, job.NextFire, 0, callBack); info != nil {
+		stored := *job
+		info.job = &stored
+	}
+	// 覆盖旧任务时不需要再删除
+	delete(this.dirtyJobs, job.TaskId)
+	this.lock.Unlock()
+	this.wakeup(job.TaskId)
+}
+
+// 记录执行后需要更新的任务，调用方需持有 lock
+func (this *CronServer) markJobLocked(info *cronInfo) {
+	if info.job == nil {
+		return
+	}
+	if info.nextFreshTime.IsZero() {
+		this.dirtyJobs[info.job.TaskId] = nil
+		return
+	}
+	job := *info.job
+	job.NextFire = info.scheduledTime
+	this.dirtyJobs[job.TaskId] = &job
+}
+
+// 取出待更新的任务，调用方需持有 lock
+func (this *CronServer) takeDirtyJobsLocked() map[string]*Job {
+	if len(this.dirtyJobs) == 0 {
+		return nil
+	}
+	dirty := this.dirtyJobs
+	this.dirtyJobs = make(map[string]*Job)
+	return dirty
+}
+
+// 把下一次执行时间写回存储，失败只记录日志，重启后按旧的 NextFire 处理
+func (this *CronServer) persistJobs(dirty map[string]*Job) {
+	for tid, job := range dirty {
+		ctx, cancel := context.WithTimeout(context.Background(), jobStoreTimeout)
+		var err error
+		if job == nil {
+			err = this.jobStore.Delete(ctx, tid)
+		} else {
+			err = this.jobStore.Save(ctx, job)
+		}
+		cancel()
+		if err != nil {
+			log.Warnf("[crontable] 任务 %s 保存失败: %v", tid, err)
+		}
+	}
+}
+
+// 保存在内存中的 JobStore
+type MemoryJobStore struct {
+	mu   sync.Mutex
+	jobs map[string]Job
+}
+
+func NewMemoryJobStore() *MemoryJobStore {
+	return &MemoryJobStore{jobs: make(map[string]Job)}
+}
+
+func (s *MemoryJobStore) Save(ctx context.Context, job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := *job
+	stored.Payload = append([]byte(nil), job.Payload...)
+	s.jobs[job.TaskId] = stored
+	return nil
+}
+
+func (s *MemoryJobStore) Delete(ctx context.Context, tid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, tid)
+	return nil
+}
+
+// 按 TaskId 排序
+func (s *MemoryJobStore) List(ctx context.Context) ([]*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]*Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		job := job
+		job.Payload = append([]byte(nil), job.Payload...)
+		jobs = append(jobs, &job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].TaskId < jobs[j].TaskId
+	})
+	return jobs, nil
+}
@@ -0,0 +1,35 @@
+package crontable
+
+import (
+	"github.com/DoOR-Team/gorm"
+	"golang.org/x/net/context"
+)
+
+// 基于 MySQL 的 JobStore，任务保存在 cron_jobs 表中
+type GormJobStore struct {
+	db *gorm.DB
+}
+
+// 会自动创建 cron_jobs 表
+func NewGormJobStore(db *gorm.DB) (*GormJobStore, error) {
+	if err := db.AutoMigrate(&Job{}).Error; err != nil {
+		return nil, err
+	}
+	return &GormJobStore{db: db}, nil
+}
+
+func (s *GormJobStore) Save(ctx context.Context, job *Job) error {
+	return s.db.Save(job).Error
+}
+
+func (s *GormJobStore) Delete(ctx context.Context, tid string) error {
+	return s.db.Where("task_id = ?", tid).Delete(&Job{}).Error
+}
+
+func (s *GormJobStore) List(ctx context.Context) ([]*Job, error) {
+	jobs := []*Job{}
+	if err := s.db.Order("task_id").Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
@@ -0,0 +1,123 @@
+package crontable
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func persistNow(c *CronServer) {
+	c.lock.Lock()
+	dirty := c.takeDirtyJobsLocked()
+	c.lock.Unlock()
+	c.persistJobs(dirty)
+}
+
+func storedJobs(t *testing.T, store JobStore) map[string]*Job {
+	t.Helper()
+	jobs, err := store.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := map[string]*Job{}
+	for _, job := range jobs {
+		m[job.TaskId] = job
+	}
+	return m
+}
+
+func TestJobStoreReload(t *testing.T) {
+	ctx := context.Background()
+	clock := newFakeClock()
+	start := clock.Now()
+	store := NewMemoryJobStore()
+	payloads := make(chan string, 10)
+	handler := func(ctx context.Context, tid string, payload []byte) error {
+		payloads <- tid + ":" + string(payload)
+		return nil
+	}
+
+	c := newCron(WithClock(clock), WithJobStore(store))
+	c.RegisterHandler("remind", handler)
+	if err := c.AddJob(ctx, Job{TaskId: "every", Spec: "@every 10s", Handler: "remind", Payload: []byte("hi")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddJob(ctx, Job{TaskId: "once", Handler: "remind", NextFire: start.Add(5 * time.Second)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddJob(ctx, Job{TaskId: "bad", Spec: "@every 1s", Handler: "missing"}); err == nil {
+		t.Fatal("unknown handler should fail")
+	}
+	if jobs := storedJobs(t, store); len(jobs) != 2 || !jobs["every"].NextFire.Equal(start.Add(10*time.Second)) {
+		t.Fatalf("stored %v", jobs)
+	}
+
+	clock.Advance(10 * time.Second)
+	c.fire(c.popDue(clock.Now()))
+	persistNow(c)
+	got := []string{<-payloads, <-payloads}
+	if strings.Join(got, ",") != "once:,every:hi" && strings.Join(got, ",") != "every:hi,once:" {
+		t.Fatalf("payloads %v", got)
+	}
+	// 一次性任务执行后删除，周期任务更新下一次执行时间
+	jobs := storedJobs(t, store)
+	if _, ok := jobs["once"]; ok || !jobs["every"].NextFire.Equal(start.Add(20*time.Second)) {
+		t.Fatalf("stored %v", jobs)
+	}
+
+	// 重启，期间错过了一次
+	clock.Advance(15 * time.Second)
+	c2 := newCron(WithClock(clock), WithJobStore(store))
+	c2.RegisterHandler("remind", handler)
+	if err := c2.LoadJobs(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if next := c2.CronMap["every"].nextFreshTime; !next.Equal(start.Add(20 * time.Second)) {
+		t.Fatalf("reloaded next fire %v", next)
+	}
+	c2.fire(c2.popDue(clock.Now()))
+	persistNow(c2)
+	if got := <-payloads; got != "every:hi" {
+		t.Fatalf("payload %s", got)
+	}
+	if jobs := storedJobs(t, store); !jobs["every"].NextFire.Equal(start.Add(30 * time.Second)) {
+		t.Fatalf("stored %v", jobs)
+	}
+}
+
+func TestJobStoreRemove(t *testing.T) {
+	ctx := context.Background()
+	clock := newFakeClock()
+	store := NewMemoryJobStore()
+	c := newCron(WithClock(clock), WithJobStore(store))
+	c.RegisterHandler("noop", func(ctx context.Context, tid string, payload []byte) error { return nil })
+
+	for _, tid := range []string{"a", "b", "c"} {
+		if err := c.AddJob(ctx, Job{TaskId: tid, Spec: "@every 1s", Handler: "noop"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.RemoveJob(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	// CronOut 以及用 CronIn 覆盖同名任务同样会删除存储中的任务
+	c.CronOut("b")
+	c.CronIn(time.Second, noopCallback("c"))
+	persistNow(c)
+	if jobs := storedJobs(t, store); len(jobs) != 0 {
+		t.Fatalf("stored %v", jobs)
+	}
+
+	store.Save(ctx, &Job{TaskId: "d", Spec: "@every 1s", Handler: "missing"})
+	store.Save(ctx, &Job{TaskId: "e", Spec: "@every 1s", Handler: "noop"})
+	c2 := newCron(WithClock(clock), WithJobStore(store))
+	c2.RegisterHandler("noop", func(ctx context.Context, tid string, payload []byte) error { return nil })
+	if err := c2.LoadJobs(ctx); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Fatalf("load err %v", err)
+	}
+	if _, ok := c2.CronMap["e"]; !ok {
+		t.Fatal("job e not loaded")
+	}
+}
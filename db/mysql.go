@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/spf13/viper"
@@ -75,11 +76,19 @@ func GetMySqlDBFromVipper(options ...tracing.TracingOption) *gorm.DB {
 		log.Println("gorm 注入tracing模块")
 	}
 
+	// 开启tracing时，需要先于tracer关闭，保证最后的span能上报
+	deps := []string{}
+	if tracing.Enable {
+		deps = append(deps, "Tracer_Cli")
+	}
 	waitgroup.AddModAndWrapServer("GORM_Client", &waitgroup.Cli{
 		CloseFunc: func() error {
 			return DB.Close()
 		},
-	})
+		CheckFunc: func(ctx context.Context) error {
+			return DB.DB().PingContext(ctx)
+		},
+	}, deps...)
 
 	// 这里一定要创建一个函数变量
 	// queryfunc := gormCallBackTraceMidWay(true, DB.Callback().Query().Get("gorm:query"))
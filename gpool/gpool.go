@@ -1,10 +1,38 @@
 package gpool
 
-import "sync"
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// 池子已满，TryAdd 无法立即拿到名额
+	ErrPoolFull = errors.New("gpool: pool is full")
+)
 
 type GPool struct {
-	queue chan int
-	wg    *sync.WaitGroup
+	wg *sync.WaitGroup
+
+	mu       sync.Mutex
+	size     int
+	inFlight int
+	// 每次有名额释放或扩容时 close 并替换，用来唤醒所有等待者
+	notify chan struct{}
+
+	waiting     int
+	totalWait   time.Duration
+	maxInFlight int
+}
+
+// 池子的运行状态快照
+type Stats struct {
+	Size        int           // 当前容量
+	InFlight    int           // 已占用的名额
+	Waiting     int           // 正在阻塞等待名额的调用方
+	TotalWait   time.Duration // 累计等待时间
+	MaxInFlight int           // 历史最高占用
 }
 
 func New(size int) *GPool {
@@ -12,26 +40,177 @@ func New(size int) *GPool {
 		size = 1
 	}
 	return &GPool{
-		queue: make(chan int, size),
-		wg:    &sync.WaitGroup{},
+		wg:     &sync.WaitGroup{},
+		size:   size,
+		notify: make(chan struct{}),
 	}
 }
 
 func (p *GPool) Add(delta int) {
 	for i := 0; i < delta; i++ {
-		p.queue <- 1
+		_ = p.acquire(context.Background(), true)
 	}
-	for i := 0; i > delta; i-- {
-		<-p.queue
+	if delta < 0 {
+		p.release(-delta)
 	}
 	p.wg.Add(delta)
 }
 
+// 与 Add 相同，但 ctx 结束时放弃等待，已拿到的名额会全部归还
+func (p *GPool) AddCtx(ctx context.Context, delta int) error {
+	if delta <= 0 {
+		p.Add(delta)
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for i := 0; i < delta; i++ {
+		if err := p.acquire(ctx, true); err != nil {
+			p.release(i)
+			return err
+		}
+	}
+	p.wg.Add(delta)
+	return nil
+}
+
+// 不阻塞，名额不够时直接返回 ErrPoolFull，已拿到的名额会全部归还
+func (p *GPool) TryAdd(delta int) error {
+	if delta <= 0 {
+		p.Add(delta)
+		return nil
+	}
+	for i := 0; i < delta; i++ {
+		if err := p.acquire(context.Background(), false); err != nil {
+			p.release(i)
+			return err
+		}
+	}
+	p.wg.Add(delta)
+	return nil
+}
+
+func (p *GPool) acquire(ctx context.Context, block bool) error {
+	p.mu.Lock()
+	if p.inFlight < p.size {
+		p.take()
+		p.mu.Unlock()
+		return nil
+	}
+	if !block {
+		p.mu.Unlock()
+		return ErrPoolFull
+	}
+
+	p.waiting++
+	start := time.Now()
+	for {
+		notify := p.notify
+		p.mu.Unlock()
+
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			p.mu.Lock()
+			p.waiting--
+			p.totalWait += time.Since(start)
+			p.mu.Unlock()
+			return ctx.Err()
+		}
+
+		p.mu.Lock()
+		if p.inFlight < p.size {
+			p.waiting--
+			p.totalWait += time.Since(start)
+			p.take()
+			p.mu.Unlock()
+			return nil
+		}
+	}
+}
+
+// 调用方需持有 p.mu
+func (p *GPool) take() {
+	p.inFlight++
+	if p.inFlight > p.maxInFlight {
+		p.maxInFlight = p.inFlight
+	}
+}
+
+func (p *GPool) release(n int) {
+	if n <= 0 {
+		return
+	}
+	p.mu.Lock()
+	p.inFlight -= n
+	p.wakeup()
+	p.mu.Unlock()
+}
+
+// 调用方需持有 p.mu
+func (p *GPool) wakeup() {
+	close(p.notify)
+	p.notify = make(chan struct{})
+}
+
 func (p *GPool) Done() {
-	<-p.queue
+	p.release(1)
 	p.wg.Done()
 }
 
 func (p *GPool) Wait() {
 	p.wg.Wait()
 }
+
+// 与 Wait 相同，但 ctx 结束时直接返回 ctx.Err()，不再等待剩余任务
+// 等待的是占用的名额全部归还，不额外启动 goroutine，ctx 结束后不会留下等待者
+func (p *GPool) WaitCtx(ctx context.Context) error {
+	p.mu.Lock()
+	for p.inFlight > 0 {
+		notify := p.notify
+		p.mu.Unlock()
+
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		p.mu.Lock()
+	}
+	p.mu.Unlock()
+	return nil
+}
+
+// 运行时调整容量；缩容时已占用的名额不受影响，新的调用方会等到占用数降到新容量以下
+func (p *GPool) Resize(size int) {
+	if size <= 0 {
+		size = 1
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	grow := size > p.size
+	p.size = size
+	if grow {
+		p.wakeup()
+	}
+}
+
+func (p *GPool) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.size
+}
+
+func (p *GPool) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return Stats{
+		Size:        p.size,
+		InFlight:    p.inFlight,
+		Waiting:     p.waiting,
+		TotalWait:   p.totalWait,
+		MaxInFlight: p.maxInFlight,
+	}
+}
@@ -1,9 +1,18 @@
 package gpool
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"runtime"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+
+	"github.com/DoOR-Team/goutils/derror"
 )
 
 func routine(test int, p *GPool) {
@@ -21,3 +30,228 @@ func TestPool(t *testing.T) {
 	}
 	pool.Wait()
 }
+
+func TestAddCtx(t *testing.T) {
+	pool := New(2)
+	if err := pool.AddCtx(context.Background(), 2); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := pool.AddCtx(ctx, 1); err != context.DeadlineExceeded {
+		t.Fatalf("expect deadline exceeded, got %v", err)
+	}
+
+	pool.Done()
+	if err := pool.AddCtx(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	pool.Done()
+	pool.Done()
+	pool.Wait()
+}
+
+func TestTryAdd(t *testing.T) {
+	pool := New(2)
+	if err := pool.TryAdd(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.TryAdd(2); err != ErrPoolFull {
+		t.Fatalf("expect ErrPoolFull, got %v", err)
+	}
+	// 失败时不应占用名额
+	if err := pool.TryAdd(1); err != nil {
+		t.Fatal(err)
+	}
+	pool.Done()
+	pool.Done()
+	pool.Wait()
+}
+
+func TestWaitCtx(t *testing.T) {
+	pool := New(1)
+	pool.Add(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := pool.WaitCtx(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expect deadline exceeded, got %v", err)
+	}
+
+	// 超时返回后不会留下等待的 goroutine
+	before := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		_ = pool.WaitCtx(ctx)
+		cancel()
+	}
+	if n := runtime.NumGoroutine(); n > before+10 {
+		t.Fatalf("goroutines: %d -> %d", before, n)
+	}
+
+	pool.Done()
+	if err := pool.WaitCtx(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWorkerPoolSubmit(t *testing.T) {
+	pool := NewWorkerPool(4)
+	futures := []*Future{}
+	for i := 0; i < 20; i++ {
+		i := i
+		futures = append(futures, pool.Submit(func(ctx context.Context) (interface{}, error) {
+			time.Sleep(time.Duration(20-i) * time.Millisecond)
+			return i, nil
+		}))
+	}
+	results, err := Collect(futures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range results {
+		if r.(int) != i {
+			t.Fatalf("result %d out of order: %v", i, r)
+		}
+	}
+	if err := pool.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWorkerPoolPanic(t *testing.T) {
+	pool := NewWorkerPool(1)
+	defer pool.Close()
+
+	_, err := pool.Submit(func(ctx context.Context) (interface{}, error) {
+		panic("boom")
+	}).Get()
+	if err == nil {
+		t.Fatal("expect panic error")
+	}
+	e, ok := errors.Cause(err).(*derror.Error)
+	if !ok || e.Info == "" {
+		t.Fatalf("expect derror with stack, got %#v", err)
+	}
+
+	// worker 在 panic 后仍然可用
+	r, err := pool.Submit(func(ctx context.Context) (interface{}, error) {
+		return "ok", nil
+	}).Get()
+	if err != nil || r != "ok" {
+		t.Fatalf("unexpected %v %v", r, err)
+	}
+}
+
+func TestWorkerPoolClose(t *testing.T) {
+	pool := NewWorkerPool(1, WithQueueSize(10))
+	var cnt int32
+	for i := 0; i < 10; i++ {
+		pool.Submit(func(ctx context.Context) (interface{}, error) {
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&cnt, 1)
+			return nil, nil
+		})
+	}
+	// Close 会先把排队中的任务执行完
+	if err := pool.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if cnt != 10 {
+		t.Fatalf("expect 10 tasks drained, got %d", cnt)
+	}
+	if _, err := pool.Submit(func(ctx context.Context) (interface{}, error) {
+		return nil, nil
+	}).Get(); err != ErrPoolClosed {
+		t.Fatalf("expect ErrPoolClosed, got %v", err)
+	}
+}
+
+func TestGroupFirstError(t *testing.T) {
+	g, ctx := NewGroup(context.Background(), 2)
+	boom := errors.New("boom")
+	var started int32
+	for i := 0; i < 10; i++ {
+		i := i
+		g.Go(func(ctx context.Context) error {
+			atomic.AddInt32(&started, 1)
+			if i == 0 {
+				return boom
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Second):
+				return nil
+			}
+		})
+	}
+	if err := g.Wait(); err != boom {
+		t.Fatalf("expect boom, got %v", err)
+	}
+	if ctx.Err() == nil {
+		t.Fatal("expect ctx canceled")
+	}
+	if started == 10 {
+		t.Fatal("expect remaining tasks skipped after failure")
+	}
+}
+
+func TestGroupAllErrors(t *testing.T) {
+	g, _ := NewGroup(context.Background(), 3, WithAllErrors())
+	for i := 0; i < 3; i++ {
+		i := i
+		g.Go(func(ctx context.Context) error {
+			time.Sleep(10 * time.Millisecond)
+			return fmt.Errorf("err %d", i)
+		})
+	}
+	err := g.Wait()
+	if n := len(multierr.Errors(err)); n != 3 {
+		t.Fatalf("expect 3 errors, got %d: %v", n, err)
+	}
+}
+
+func TestResize(t *testing.T) {
+	pool := New(1)
+	pool.Add(1)
+	if err := pool.TryAdd(1); err != ErrPoolFull {
+		t.Fatalf("expect ErrPoolFull, got %v", err)
+	}
+
+	// 扩容后阻塞中的调用方会被唤醒
+	acquired := make(chan struct{})
+	go func() {
+		pool.Add(1)
+		close(acquired)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if s := pool.Stats(); s.Waiting != 1 {
+		t.Fatalf("expect 1 waiting, got %+v", s)
+	}
+	pool.Resize(2)
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("waiter not woken up after grow")
+	}
+
+	// 缩容后需要占用数降到新容量以下才能再拿到名额
+	pool.Resize(1)
+	pool.Done()
+	if err := pool.TryAdd(1); err != ErrPoolFull {
+		t.Fatalf("expect ErrPoolFull after shrink, got %v", err)
+	}
+	pool.Done()
+	if err := pool.TryAdd(1); err != nil {
+		t.Fatal(err)
+	}
+	pool.Done()
+	pool.Wait()
+
+	s := pool.Stats()
+	if s.Size != 1 || s.InFlight != 0 || s.Waiting != 0 || s.MaxInFlight != 2 || s.TotalWait <= 0 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+}
@@ -0,0 +1,98 @@
+package gpool
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/multierr"
+)
+
+// 类似 errgroup，并发度受 GPool 限制，第一个失败的任务会取消共享的 ctx
+type Group struct {
+	pool   *GPool
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel func()
+
+	collectAll bool
+
+	mu  sync.Mutex
+	err error
+}
+
+type groupOptions struct {
+	collectAll bool
+}
+
+type GroupOption func(*groupOptions)
+
+// Wait 返回所有任务的错误（multierr 合并），默认只返回第一个错误
+func WithAllErrors() GroupOption {
+	return func(options *groupOptions) {
+		options.collectAll = true
+	}
+}
+
+func NewGroup(ctx context.Context, size int, opts ...GroupOption) (*Group, context.Context) {
+	return NewGroupWithPool(ctx, New(size), opts...)
+}
+
+// 与其他调用方共用同一个 GPool 的并发限制
+func NewGroupWithPool(ctx context.Context, pool *GPool, opts ...GroupOption) (*Group, context.Context) {
+	options := &groupOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	return &Group{
+		pool:       pool,
+		ctx:        ctx,
+		cancel:     cancel,
+		collectAll: options.collectAll,
+	}, ctx
+}
+
+// 阻塞直到拿到名额再启动 f；ctx 已结束时不再启动
+func (g *Group) Go(f func(ctx context.Context) error) {
+	if err := g.ctx.Err(); err != nil {
+		g.fail(err, false)
+		return
+	}
+	if err := g.pool.AddCtx(g.ctx, 1); err != nil {
+		g.fail(err, false)
+		return
+	}
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer g.pool.Done()
+
+		if err := f(g.ctx); err != nil {
+			g.fail(err, true)
+		}
+	}()
+}
+
+func (g *Group) fail(err error, fromTask bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	// 因取消而没有启动的任务，只在还没有其他错误时记录
+	if g.err == nil {
+		g.err = err
+	} else if g.collectAll && fromTask {
+		g.err = multierr.Append(g.err, err)
+	}
+	g.cancel()
+}
+
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.err
+}
@@ -0,0 +1,203 @@
+package gpool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/DoOR-Team/goutils/derror"
+	"github.com/DoOR-Team/goutils/log"
+	"github.com/DoOR-Team/goutils/trace"
+)
+
+var (
+	// 池子已经 Close，不再接收新任务
+	ErrPoolClosed = errors.New("gpool: pool is closed")
+)
+
+type Task func(ctx context.Context) (interface{}, error)
+
+type Future struct {
+	done   chan struct{}
+	result interface{}
+	err    error
+}
+
+func newFuture() *Future {
+	return &Future{done: make(chan struct{})}
+}
+
+func (f *Future) resolve(result interface{}, err error) {
+	f.result = result
+	f.err = err
+	close(f.done)
+}
+
+// 任务结束后关闭，可用于 select
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// 阻塞直到任务结束
+func (f *Future) Get() (interface{}, error) {
+	<-f.done
+	return f.result, f.err
+}
+
+// 与 Get 相同，但 ctx 结束时直接返回 ctx.Err()，任务本身不会被取消
+func (f *Future) GetCtx(ctx context.Context) (interface{}, error) {
+	select {
+	case <-f.done:
+		return f.result, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// 按 futures 的顺序返回结果，error 为顺序上第一个失败的任务的错误
+func Collect(futures []*Future) ([]interface{}, error) {
+	results := make([]interface{}, len(futures))
+	var firstErr error
+	for i, f := range futures {
+		r, err := f.Get()
+		results[i] = r
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return results, firstErr
+}
+
+type job struct {
+	ctx    context.Context
+	task   Task
+	future *Future
+}
+
+// 常驻 worker 的任务池，并发度与排队长度都由内部的 GPool 限制
+// 实现了 Close() error，可直接作为 waitgroup.Mod 注册
+type WorkerPool struct {
+	pool *GPool
+
+	jobs    chan *job
+	workers sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+type workerPoolOptions struct {
+	queueSize int
+}
+
+type WorkerPoolOption func(*workerPoolOptions)
+
+// 排队中的任务数上限，超过后 Submit 阻塞，默认与 worker 数一致
+func WithQueueSize(size int) WorkerPoolOption {
+	return func(options *workerPoolOptions) {
+		options.queueSize = size
+	}
+}
+
+func NewWorkerPool(workers int, opts ...WorkerPoolOption) *WorkerPool {
+	if workers <= 0 {
+		workers = 1
+	}
+	options := &workerPoolOptions{queueSize: workers}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if options.queueSize < 0 {
+		options.queueSize = 0
+	}
+
+	// 名额 = 运行中 + 排队中，拿到名额后写 jobs 永远不会阻塞
+	capacity := workers + options.queueSize
+	p := &WorkerPool{
+		pool: New(capacity),
+		jobs: make(chan *job, capacity),
+	}
+	for i := 0; i < workers; i++ {
+		p.workers.Add(1)
+		go p.work()
+	}
+	return p
+}
+
+func (p *WorkerPool) work() {
+	defer p.workers.Done()
+	for j := range p.jobs {
+		j.future.resolve(runTask(j.ctx, j.task))
+		p.pool.Done()
+	}
+}
+
+func runTask(ctx context.Context, task Task) (result interface{}, err error) {
+	defer func() {
+		if perr := recover(); perr != nil {
+			stack := string(trace.PanicTrace(10))
+			log.Error(stack)
+			e := derror.NewNoTipsError(fmt.Sprintf("%v", perr))
+			e.Info = stack
+			result = nil
+			err = errors.Wrap(e, "panic")
+		}
+	}()
+	return task(ctx)
+}
+
+// 提交任务，队列满时阻塞
+func (p *WorkerPool) Submit(task Task) *Future {
+	p.pool.Add(1)
+	return p.enqueue(context.Background(), task)
+}
+
+// 与 Submit 相同，但 ctx 结束时放弃排队，ctx 同时会传给 task
+func (p *WorkerPool) SubmitCtx(ctx context.Context, task Task) *Future {
+	if err := p.pool.AddCtx(ctx, 1); err != nil {
+		f := newFuture()
+		f.resolve(nil, err)
+		return f
+	}
+	return p.enqueue(ctx, task)
+}
+
+func (p *WorkerPool) enqueue(ctx context.Context, task Task) *Future {
+	f := newFuture()
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		p.pool.Done()
+		f.resolve(nil, ErrPoolClosed)
+		return f
+	}
+	p.jobs <- &job{ctx: ctx, task: task, future: f}
+	return f
+}
+
+// 等待已提交的任务全部执行完
+func (p *WorkerPool) Wait() {
+	p.pool.Wait()
+}
+
+func (p *WorkerPool) WaitCtx(ctx context.Context) error {
+	return p.pool.WaitCtx(ctx)
+}
+
+// 不再接收新任务，等待已提交的任务全部执行完后退出 worker
+func (p *WorkerPool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrPoolClosed
+	}
+	p.closed = true
+	close(p.jobs)
+	p.mu.Unlock()
+
+	p.workers.Wait()
+	return nil
+}
@@ -5,6 +5,8 @@ import (
 
 	"github.com/spf13/viper"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 
 	"github.com/DoOR-Team/goutils/log"
 	"github.com/DoOR-Team/goutils/waitgroup"
@@ -39,16 +41,24 @@ func WrapServeGRPCWithUserInfo(register RegisterServerHandler, userInfoKey strin
 	}
 	s := grpc.NewServer(grpc.UnaryInterceptor(ServerInterceptor))
 	register(s)
+	// Serve 之前为 NOT_SERVING，Close 之后也不再是 SERVING
+	hs := health.NewServer()
+	hs.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(s, hs)
 
 	err = waitgroup.AddModAndWrapServer("GRPC_Server", &Srv{
 		ServeFunc: func() error {
 			log.Infof("监听服务地址 [%s] 成功。", viper.GetString("grpc_address"))
+			hs.Resume()
+			defer hs.Shutdown()
 			return s.Serve(lis)
 		},
 		CloseFunc: func() error {
+			hs.Shutdown()
 			s.GracefulStop()
 			return nil
 		},
+		CheckFunc: checkHealthServer(hs),
 	})
 	if err != nil {
 		panic(err)
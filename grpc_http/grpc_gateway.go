@@ -3,9 +3,11 @@ package grpc_http
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"sync/atomic"
 
 	"context"
 
@@ -13,14 +15,18 @@ import (
 	"github.com/grpc-ecosystem/grpc-gateway/runtime"
 	spb "google.golang.org/genproto/googleapis/rpc/status"
 	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/status"
 
 	"github.com/DoOR-Team/goutils/derror"
+	"github.com/DoOR-Team/goutils/waitgroup"
 )
 
 type Srv struct {
 	CloseFunc func() error
 	ServeFunc func() error
+	// 可选，用于就绪检查
+	CheckFunc func(ctx context.Context) error
 }
 
 func (h *Srv) Close() error {
@@ -31,6 +37,49 @@ func (h *Srv) Serve() error {
 	return h.ServeFunc()
 }
 
+func (h *Srv) Check(ctx context.Context) error {
+	if h.CheckFunc == nil {
+		return nil
+	}
+	return h.CheckFunc(ctx)
+}
+
+// Serve 运行期间就绪，Close 或者 Serve 返回后不再就绪，用于 HTTP Server 的就绪检查
+type serveFlag struct {
+	serving int32
+}
+
+func (f *serveFlag) serve(fn func() error) error {
+	atomic.StoreInt32(&f.serving, 1)
+	defer atomic.StoreInt32(&f.serving, 0)
+	return fn()
+}
+
+func (f *serveFlag) close() {
+	atomic.StoreInt32(&f.serving, 0)
+}
+
+func (f *serveFlag) check(ctx context.Context) error {
+	if atomic.LoadInt32(&f.serving) == 0 {
+		return errors.New("server is not serving")
+	}
+	return nil
+}
+
+// 在进程内调用 grpc_health_v1 的 Check，用于 GRPC Server 的就绪检查
+func checkHealthServer(hs healthpb.HealthServer) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		resp, err := hs.Check(ctx, &healthpb.HealthCheckRequest{})
+		if err != nil {
+			return err
+		}
+		if resp.Status != healthpb.HealthCheckResponse_SERVING {
+			return fmt.Errorf("grpc health status %s", resp.Status)
+		}
+		return nil
+	}
+}
+
 type Cli struct {
 	CloseFunc func() error
 }
@@ -144,9 +193,8 @@ func (m *JSONMarshaler) NewEncoder(w io.Writer) runtime.Encoder {
 
 func NewHttpRouter() *mux.Router {
 	r := mux.NewRouter()
-	r.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintf(w, "ok")
-	})
+	r.Handle("/healthz", waitgroup.HealthzHandler())
+	r.Handle("/readyz", waitgroup.ReadyzHandler())
 	//AgiProfiling(r)
 	return r
 }
@@ -23,14 +23,19 @@ func WrapListenAndServe(r http.Handler) error {
 		Handler: r,
 		// ErrorLog: log.New(os.Stdout, "", 0),
 	}
+	flag := &serveFlag{}
 	err = waitgroup.AddModAndWrapServer("HTTP_Server", &Srv{
 		ServeFunc: func() error {
 			log.Infof("listen grpc_http(%s)", lis.Addr().String())
-			return s.Serve(lis)
+			return flag.serve(func() error {
+				return s.Serve(lis)
+			})
 		},
 		CloseFunc: func() error {
+			flag.close()
 			return s.Close()
 		},
+		CheckFunc: flag.check,
 	})
 	if err != nil {
 		panic(err)
@@ -48,14 +53,19 @@ func WrapListenAndServeWithTls(r http.Handler, crtfile, keyfile string) error {
 		Handler: r,
 		// ErrorLog: log.New(os.Stdout, "", 0),
 	}
+	flag := &serveFlag{}
 	err = waitgroup.AddModAndWrapServer("HTTP_Server", &Srv{
 		ServeFunc: func() error {
 			log.Infof("listen grpc_http(%s)", lis.Addr().String())
-			return s.ServeTLS(lis, crtfile, keyfile)
+			return flag.serve(func() error {
+				return s.ServeTLS(lis, crtfile, keyfile)
+			})
 		},
 		CloseFunc: func() error {
+			flag.close()
 			return s.Close()
 		},
+		CheckFunc: flag.check,
 	})
 	if err != nil {
 		panic(err)
@@ -155,6 +155,7 @@ func NewWithVhost(rmqBaseAddr string) *RMQ {
 			rmq.Destory()
 			return nil
 		},
+		CheckFunc: rmq.Check,
 	})
 	return rmq
 }
@@ -174,6 +175,14 @@ func newrmq(host string) *RMQ {
 	return rmq
 }
 
+// 连接是否可用，断线重连期间会返回错误
+func (rmq *RMQ) Check(ctx context.Context) error {
+	if rmq.conn == nil || rmq.conn.IsClosed() {
+		return fmt.Errorf("rabbit-mq connection %s is closed", rmq.amqpUri)
+	}
+	return nil
+}
+
 func (rmq *RMQ) Destory() {
 	rmq.publishChannel.Close()
 	rmq.consumChannel.Close()
@@ -356,6 +356,7 @@ var hetuCheckReqFunc = func(r *http.Request, forServer bool) error {
 	if strings.HasPrefix(r.URL.Path, "/debug/pprof") ||
 		strings.HasSuffix(r.URL.Path, "/status") ||
 		strings.HasSuffix(r.URL.Path, "/healthz") ||
+		strings.HasSuffix(r.URL.Path, "/readyz") ||
 		strings.HasPrefix(r.URL.Path, "/metrics") {
 		return ErrUnneededTracing
 	}
@@ -0,0 +1,176 @@
+package waitgroup
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 模块可选实现，用于 /readyz 检查依赖是否可用
+type HealthChecker interface {
+	Check(ctx context.Context) error
+}
+
+const (
+	HealthStatusOK   = "ok"
+	HealthStatusFail = "fail"
+)
+
+// 单次健康检查的默认超时时间
+var HealthCheckTimeout = 3 * time.Second
+
+type ModHealth struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Restarts int    `json:"restarts,omitempty"`
+}
+
+type HealthReport struct {
+	Status string                `json:"status"`
+	Reason string                `json:"reason,omitempty"`
+	Mods   map[string]*ModHealth `json:"mods,omitempty"`
+}
+
+func (r *HealthReport) OK() bool {
+	return r.Status == HealthStatusOK
+}
+
+func (r *HealthReport) fail(reason string) {
+	r.Status = HealthStatusFail
+	if r.Reason == "" {
+		r.Reason = reason
+	}
+}
+
+// 存活检查：所有 Server 模块的 Serve 都还在运行，不调用 HealthChecker，避免依赖故障导致重启
+func (w *Registry) Liveness() *HealthReport {
+	report := &HealthReport{Status: HealthStatusOK, Mods: map[string]*ModHealth{}}
+
+	w.stateMu.Lock()
+	defer w.stateMu.Unlock()
+	if w.closed {
+		report.fail("closed")
+		return report
+	}
+	for _, name := range w.names {
+		if _, ok := w.mods[name].(Server); !ok {
+			continue
+		}
+		if _, ok := w.serving[name]; ok {
+			report.Mods[name] = &ModHealth{Status: HealthStatusOK, Restarts: w.restarts[name]}
+			continue
+		}
+		report.Mods[name] = &ModHealth{Status: HealthStatusFail, Error: "not serving", Restarts: w.restarts[name]}
+		report.fail("mod " + name + " not serving")
+	}
+	return report
+}
+
+// 就绪检查：已初始化、未 draining，所有 Server 模块处于 serving 状态，并且所有实现了 HealthChecker 的模块检查通过
+func (w *Registry) Readiness(ctx context.Context) *HealthReport {
+	report := &HealthReport{Status: HealthStatusOK, Mods: map[string]*ModHealth{}}
+
+	w.stateMu.Lock()
+	inited, closed := w.inited, w.closed
+	checkers := map[string]HealthChecker{}
+	notServing := []string{}
+	for _, name := range w.names {
+		if _, ok := w.mods[name].(Server); ok && w.states[name] != StateServing {
+			// 重启等待中或者已经退出的 Server 不再调用 Check
+			notServing = append(notServing, name)
+			continue
+		}
+		if hc, ok := w.mods[name].(HealthChecker); ok {
+			checkers[name] = hc
+		}
+	}
+	w.stateMu.Unlock()
+
+	switch {
+	case closed:
+		report.fail("closed")
+		return report
+	case !inited:
+		report.fail("initializing")
+		return report
+	case w.IsDraining():
+		report.fail("draining")
+		return report
+	}
+	for _, name := range notServing {
+		report.Mods[name] = &ModHealth{Status: HealthStatusFail, Error: "not serving"}
+		report.fail("mod " + name + " not serving")
+	}
+
+	var (
+		resultMu sync.Mutex
+		wg       sync.WaitGroup
+	)
+	for name, hc := range checkers {
+		name, hc := name, hc
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, HealthCheckTimeout)
+			defer cancel()
+
+			health := &ModHealth{Status: HealthStatusOK}
+			if err := hc.Check(ctx); err != nil {
+				health.Status = HealthStatusFail
+				health.Error = err.Error()
+			}
+
+			resultMu.Lock()
+			report.Mods[name] = health
+			resultMu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	names := []string{}
+	for name, health := range report.Mods {
+		if health.Status != HealthStatusOK {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		report.fail("mod " + name + " check failed")
+	}
+	return report
+}
+
+func writeHealthReport(rw http.ResponseWriter, report *HealthReport) {
+	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if report.OK() {
+		rw.WriteHeader(http.StatusOK)
+	} else {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(rw).Encode(report)
+}
+
+func (w *Registry) HealthzHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		writeHealthReport(rw, w.Liveness())
+	})
+}
+
+func (w *Registry) ReadyzHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		writeHealthReport(rw, w.Readiness(r.Context()))
+	})
+}
+
+// /healthz 存活检查
+func HealthzHandler() http.Handler {
+	return defaultRegistry.HealthzHandler()
+}
+
+// /readyz 就绪检查
+func ReadyzHandler() http.Handler {
+	return defaultRegistry.ReadyzHandler()
+}
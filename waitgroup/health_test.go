@@ -0,0 +1,107 @@
+package waitgroup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReadiness(t *testing.T) {
+	w := New()
+	dbErr := error(nil)
+	_ = w.AddModAndWrapServer("GORM_Client", &Cli{
+		CloseFunc: func() error { return nil },
+		CheckFunc: func(ctx context.Context) error { return dbErr },
+	})
+	_ = w.AddModAndWrapServer("Tracer_Cli", &NoopMod{})
+
+	if r := w.Readiness(context.Background()); r.OK() || r.Reason != "initializing" {
+		t.Fatalf("expect not ready before init, got %+v", r)
+	}
+
+	w.inited = true
+	if r := w.Readiness(context.Background()); !r.OK() || r.Mods["GORM_Client"].Status != HealthStatusOK {
+		t.Fatalf("expect ready, got %+v", r)
+	}
+
+	dbErr = errors.New("connection refused")
+	rec := httptest.NewRecorder()
+	w.ReadyzHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expect 503, got %d", rec.Code)
+	}
+	report := &HealthReport{}
+	if err := json.Unmarshal(rec.Body.Bytes(), report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Mods["GORM_Client"].Error != "connection refused" {
+		t.Fatalf("unexpected report %s", rec.Body.String())
+	}
+
+	dbErr = nil
+	w.draining = 1
+	if r := w.Readiness(context.Background()); r.OK() || r.Reason != "draining" {
+		t.Fatalf("expect not ready when draining, got %+v", r)
+	}
+}
+
+func TestLiveness(t *testing.T) {
+	w := New()
+	stop := make(chan struct{})
+	_ = w.AddModAndWrapServer("HTTP_Server", &Srv{
+		ServeFunc: func() error {
+			<-stop
+			return nil
+		},
+		CloseFunc: func() error { return nil },
+	})
+
+	rec := httptest.NewRecorder()
+	w.HealthzHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expect 200, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	close(stop)
+	w.Wait()
+	if r := w.Liveness(); r.OK() || r.Mods["HTTP_Server"].Status != HealthStatusFail {
+		t.Fatalf("expect HTTP_Server not serving, got %+v", r)
+	}
+}
+
+func TestReadinessServerState(t *testing.T) {
+	w := New()
+	stop := make(chan struct{})
+	_ = w.AddModAndWrapServer("HTTP_Server", &Srv{
+		ServeFunc: func() error {
+			<-stop
+			return nil
+		},
+		CloseFunc: func() error { return nil },
+	})
+	w.inited = true
+
+	for i := 0; ; i++ {
+		if state, _ := w.State("HTTP_Server"); state == StateServing {
+			break
+		}
+		if i > 100 {
+			t.Fatal("timeout waiting for serving")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if r := w.Readiness(context.Background()); !r.OK() {
+		t.Fatalf("expect ready, got %+v", r)
+	}
+
+	// Serve 返回后不再就绪
+	close(stop)
+	w.Wait()
+	if r := w.Readiness(context.Background()); r.OK() || r.Reason != "mod HTTP_Server not serving" {
+		t.Fatalf("expect HTTP_Server not ready, got %+v", r)
+	}
+}
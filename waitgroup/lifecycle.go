@@ -0,0 +1,188 @@
+package waitgroup
+
+import (
+	"sort"
+
+	"go.uber.org/multierr"
+)
+
+type ModState int
+
+const (
+	// 已注册 creator，还未初始化
+	StateRegistered ModState = iota
+	// 初始化中，或者 Server 在等待重启
+	StateStarting
+	// 运行中，Client 初始化完成后即为此状态
+	StateServing
+	// 正在 Close，或 Close 超时仍未返回
+	StateClosing
+	// 已关闭，或者 Serve 正常结束
+	StateClosed
+	// 初始化、Serve 或者 Close 出错
+	StateFailed
+)
+
+var modStateNames = map[ModState]string{
+	StateRegistered: "registered",
+	StateStarting:   "starting",
+	StateServing:    "serving",
+	StateClosing:    "closing",
+	StateClosed:     "closed",
+	StateFailed:     "failed",
+}
+
+func (s ModState) String() string {
+	if name, ok := modStateNames[s]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+func (s ModState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// 模块的快照信息
+type ModInfo struct {
+	Name     string
+	State    ModState
+	Deps     []string
+	Server   bool
+	Restarts int
+	// creator 还未初始化时为 nil
+	Mod Mod
+}
+
+// 生命周期回调，启动前回调返回错误时模块不会被添加
+type Hook func(name string, mod Mod) error
+
+type hookKind int
+
+const (
+	hookBeforeStart hookKind = iota
+	hookAfterStart
+	hookBeforeClose
+	hookAfterClose
+)
+
+type hooks map[hookKind][]Hook
+
+func (w *Registry) addHook(kind hookKind, h Hook) {
+	w.stateMu.Lock()
+	defer w.stateMu.Unlock()
+	if w.hooks == nil {
+		w.hooks = hooks{}
+	}
+	w.hooks[kind] = append(w.hooks[kind], h)
+}
+
+func (w *Registry) hooksOf(kind hookKind) []Hook {
+	w.stateMu.Lock()
+	defer w.stateMu.Unlock()
+	return append([]Hook{}, w.hooks[kind]...)
+}
+
+func (w *Registry) runHooks(hs []Hook, name string, mod Mod) error {
+	var errs error
+	for _, h := range hs {
+		errs = multierr.Append(errs, h(name, mod))
+	}
+	return errs
+}
+
+func (w *Registry) OnBeforeStart(h Hook) {
+	w.addHook(hookBeforeStart, h)
+}
+
+func (w *Registry) OnAfterStart(h Hook) {
+	w.addHook(hookAfterStart, h)
+}
+
+func (w *Registry) OnBeforeClose(h Hook) {
+	w.addHook(hookBeforeClose, h)
+}
+
+func (w *Registry) OnAfterClose(h Hook) {
+	w.addHook(hookAfterClose, h)
+}
+
+func (w *Registry) setState(name string, state ModState) {
+	w.stateMu.Lock()
+	defer w.stateMu.Unlock()
+	w.states[name] = state
+}
+
+func (w *Registry) State(name string) (ModState, bool) {
+	w.stateMu.Lock()
+	defer w.stateMu.Unlock()
+	state, ok := w.states[name]
+	return state, ok
+}
+
+// 所有模块的快照，已添加的模块按初始化顺序在前，未初始化的 creator 按名字排在后面
+func (w *Registry) Mods() []ModInfo {
+	pending := creatorWrappers{}
+	w.creatorMu.Lock()
+	for _, cw := range w.creators {
+		pending = append(pending, cw)
+	}
+	w.creatorMu.Unlock()
+
+	w.stateMu.Lock()
+	defer w.stateMu.Unlock()
+
+	infos := make([]ModInfo, 0, len(w.names)+len(pending))
+	for _, name := range w.names {
+		mod := w.mods[name]
+		_, isServer := mod.(Server)
+		infos = append(infos, ModInfo{
+			Name:     name,
+			State:    w.states[name],
+			Deps:     w.deps[name],
+			Server:   isServer,
+			Restarts: w.restarts[name],
+			Mod:      mod,
+		})
+	}
+
+	pendingInfos := []ModInfo{}
+	for _, cw := range pending {
+		if _, ok := w.mods[cw.name]; ok {
+			continue
+		}
+		state, ok := w.states[cw.name]
+		if !ok {
+			state = StateRegistered
+		}
+		pendingInfos = append(pendingInfos, ModInfo{
+			Name:  cw.name,
+			State: state,
+			Deps:  cw.deps,
+		})
+	}
+	sort.Slice(pendingInfos, func(i, j int) bool {
+		return pendingInfos[i].Name < pendingInfos[j].Name
+	})
+	return append(infos, pendingInfos...)
+}
+
+func OnBeforeStart(h Hook) {
+	defaultRegistry.OnBeforeStart(h)
+}
+
+func OnAfterStart(h Hook) {
+	defaultRegistry.OnAfterStart(h)
+}
+
+func OnBeforeClose(h Hook) {
+	defaultRegistry.OnBeforeClose(h)
+}
+
+func OnAfterClose(h Hook) {
+	defaultRegistry.OnAfterClose(h)
+}
+
+func Mods() []ModInfo {
+	return defaultRegistry.Mods()
+}
@@ -0,0 +1,107 @@
+package waitgroup
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func modStates(w *Registry) string {
+	states := []string{}
+	for _, info := range w.Mods() {
+		states = append(states, fmt.Sprintf("%s=%s", info.Name, info.State))
+	}
+	return strings.Join(states, ",")
+}
+
+func TestRegistryLifecycle(t *testing.T) {
+	w := New()
+	stop := make(chan struct{})
+	w.AddModCreator("Tracer_Cli", 0, noopCreator)
+	w.AddModCreator("HTTP_Server", 1, func() Mod {
+		return &Srv{
+			ServeFunc: func() error {
+				<-stop
+				return nil
+			},
+			CloseFunc: func() error {
+				close(stop)
+				return nil
+			},
+		}
+	}, "Tracer_Cli")
+
+	if got := modStates(w); got != "HTTP_Server=registered,Tracer_Cli=registered" {
+		t.Fatalf("unexpected states before init %s", got)
+	}
+
+	var mu sync.Mutex
+	events := []string{}
+	record := func(event string) Hook {
+		return func(name string, mod Mod) error {
+			mu.Lock()
+			events = append(events, event+":"+name)
+			mu.Unlock()
+			return nil
+		}
+	}
+	w.OnBeforeStart(record("beforeStart"))
+	w.OnAfterStart(record("afterStart"))
+	w.OnBeforeClose(record("beforeClose"))
+	w.OnAfterClose(record("afterClose"))
+
+	if err := w.InitModsAndWrapServersWithExitFunc(nil); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if got := modStates(w); got != "Tracer_Cli=serving,HTTP_Server=serving" {
+		t.Fatalf("unexpected states after init %s", got)
+	}
+	if err := w.InitModsAndWrapServersWithExitFunc(nil); err == nil {
+		t.Fatal("expect error when init twice")
+	}
+
+	if err := w.CloseModsAndWait(); err != nil {
+		t.Fatal(err)
+	}
+	if got := modStates(w); got != "Tracer_Cli=closed,HTTP_Server=closed" {
+		t.Fatalf("unexpected states after close %s", got)
+	}
+
+	expect := "beforeStart:Tracer_Cli,afterStart:Tracer_Cli,beforeStart:HTTP_Server,afterStart:HTTP_Server," +
+		"beforeClose:HTTP_Server,afterClose:HTTP_Server,beforeClose:Tracer_Cli,afterClose:Tracer_Cli"
+	if got := strings.Join(events, ","); got != expect {
+		t.Fatalf("unexpected hook events %s", got)
+	}
+
+	// 另一个 Registry 互不影响
+	other := New()
+	if len(other.Mods()) != 0 {
+		t.Fatal("expect new registry empty")
+	}
+}
+
+func TestBeforeStartHookRejects(t *testing.T) {
+	w := New()
+	w.OnBeforeStart(func(name string, mod Mod) error {
+		if name == "RMQ_Client" {
+			return errors.New("rmq disabled in test")
+		}
+		return nil
+	})
+	if err := w.AddModAndWrapServer("RMQ_Client", &NoopMod{}); err == nil {
+		t.Fatal("expect before start hook error")
+	}
+	if state, _ := w.State("RMQ_Client"); state != StateFailed {
+		t.Fatalf("expect failed, got %s", state)
+	}
+	if err := w.AddModAndWrapServer("GORM_Client", &NoopMod{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := modStates(w); got != "GORM_Client=serving" {
+		t.Fatalf("unexpected states %s", got)
+	}
+}
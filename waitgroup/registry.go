@@ -1,7 +1,10 @@
 package waitgroup
 
-import "sync"
-import "sort"
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
 
 type Creator func() Mod
 
@@ -9,11 +12,10 @@ type creatorWrapper struct {
 	c     Creator
 	order int
 	name  string
+	// 依赖的模块名，这些模块会先于本模块初始化、晚于本模块关闭
+	deps []string
 }
 
-var creators = map[string]*creatorWrapper{}
-var mu = sync.Mutex{}
-
 type creatorWrappers []*creatorWrapper
 
 func (s creatorWrappers) Len() int {
@@ -23,37 +25,141 @@ func (s creatorWrappers) Swap(i, j int) {
 	s[i], s[j] = s[j], s[i]
 }
 func (s creatorWrappers) Less(i, j int) bool {
-	return s[i].order < s[j].order
+	if s[i].order != s[j].order {
+		return s[i].order < s[j].order
+	}
+	return s[i].name < s[j].name
 }
 
-func AddModCreator(name string, order int, creator Creator) {
-	mu.Lock()
-	defer mu.Unlock()
+// deps 为依赖的模块名，可以是其他 creator，也可以是已经通过 AddModAndWrapServer 注册的模块
+// 初始化顺序以依赖关系为准，没有依赖关系的模块之间再按 order、name 排序
+func (w *Registry) AddModCreator(name string, order int, creator Creator, deps ...string) {
+	w.creatorMu.Lock()
+	defer w.creatorMu.Unlock()
 
-	creators[name] = &creatorWrapper{
+	w.creators[name] = &creatorWrapper{
 		c:     creator,
 		order: order,
 		name:  name,
+		deps:  deps,
 	}
 }
 
-func ModCreatorsCount() int {
-	mu.Lock()
-	defer mu.Unlock()
+func (w *Registry) ModCreatorsCount() int {
+	w.creatorMu.Lock()
+	defer w.creatorMu.Unlock()
 
-	return len(creators)
+	return len(w.creators)
 }
 
-func sortCreators(asc bool) []*creatorWrapper {
-	cws := creatorWrappers{}
+func AddModCreator(name string, order int, creator Creator, deps ...string) {
+	defaultRegistry.AddModCreator(name, order, creator, deps...)
+}
+
+func ModCreatorsCount() int {
+	return defaultRegistry.ModCreatorsCount()
+}
+
+// 按依赖关系拓扑排序，registered 为已经注册好的模块，依赖它们视为已满足
+func (w *Registry) sortCreators(registered func(name string) bool) ([]*creatorWrapper, error) {
+	w.creatorMu.Lock()
+	defer w.creatorMu.Unlock()
+	creators := w.creators
+
+	pending := map[string]int{}
+	dependents := map[string][]*creatorWrapper{}
+	for _, cw := range creators {
+		cnt := 0
+		for _, dep := range cw.deps {
+			if _, ok := creators[dep]; ok {
+				cnt++
+				dependents[dep] = append(dependents[dep], cw)
+				continue
+			}
+			if !registered(dep) {
+				return nil, fmt.Errorf("[waitgroup] Mod %s depends on missing mod %s", cw.name, dep)
+			}
+		}
+		pending[cw.name] = cnt
+	}
+
+	ready := creatorWrappers{}
 	for _, cw := range creators {
-		cws = append(cws, cw)
+		if pending[cw.name] == 0 {
+			ready = append(ready, cw)
+		}
+	}
+
+	sorted := make([]*creatorWrapper, 0, len(creators))
+	for len(ready) > 0 {
+		sort.Sort(ready)
+		cw := ready[0]
+		ready = ready[1:]
+		sorted = append(sorted, cw)
+
+		for _, next := range dependents[cw.name] {
+			pending[next.name]--
+			if pending[next.name] == 0 {
+				ready = append(ready, next)
+			}
+		}
+	}
+
+	if len(sorted) < len(creators) {
+		return nil, fmt.Errorf("[waitgroup] Mod dependency cycle: %s", findCycle(creators, pending))
+	}
+	return sorted, nil
+}
+
+// 在还有未满足依赖的模块里找出一条环，用于报错
+func findCycle(creators map[string]*creatorWrapper, pending map[string]int) string {
+	names := []string{}
+	for name, cnt := range pending {
+		if cnt > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	path := []string{}
+	var cycle []string
+	var visit func(name string) bool
+	visit = func(name string) bool {
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range creators[name].deps {
+			if _, ok := creators[dep]; !ok {
+				continue
+			}
+			switch state[dep] {
+			case visiting:
+				for i, n := range path {
+					if n == dep {
+						cycle = append(append([]string{}, path[i:]...), dep)
+						return true
+					}
+				}
+			case unvisited:
+				if visit(dep) {
+					return true
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		return false
 	}
 
-	if asc {
-		sort.Sort(cws)
-	} else {
-		sort.Sort(sort.Reverse(cws))
+	for _, name := range names {
+		if state[name] == unvisited && visit(name) {
+			return strings.Join(cycle, " -> ")
+		}
 	}
-	return cws
+	return strings.Join(names, ", ")
 }
@@ -0,0 +1,67 @@
+package waitgroup
+
+import (
+	"strings"
+	"testing"
+)
+
+func noopCreator() Mod {
+	return &NoopMod{}
+}
+
+func creatorNames(cws []*creatorWrapper) []string {
+	names := []string{}
+	for _, cw := range cws {
+		names = append(names, cw.name)
+	}
+	return names
+}
+
+func noneRegistered(string) bool {
+	return false
+}
+
+func TestSortCreatorsByDeps(t *testing.T) {
+	w := New()
+	w.AddModCreator("GORM_Client", 0, noopCreator, "Tracer_Cli")
+	w.AddModCreator("Tracer_Cli", 10, noopCreator)
+	w.AddModCreator("B", 0, noopCreator)
+	w.AddModCreator("A", 0, noopCreator)
+	w.AddModCreator("HTTP_Server", -1, noopCreator, "GORM_Client", "A")
+
+	sorted, err := w.sortCreators(noneRegistered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(creatorNames(sorted), ",")
+	if got != "A,B,Tracer_Cli,GORM_Client,HTTP_Server" {
+		t.Fatalf("unexpected order %s", got)
+	}
+}
+
+func TestSortCreatorsMissingDep(t *testing.T) {
+	w := New()
+	w.AddModCreator("GORM_Client", 0, noopCreator, "Tracer_Cli")
+	if _, err := w.sortCreators(noneRegistered); err == nil || !strings.Contains(err.Error(), "Tracer_Cli") {
+		t.Fatalf("expect missing dep error, got %v", err)
+	}
+
+	// 已经注册的模块也可以作为依赖
+	sorted, err := w.sortCreators(func(name string) bool { return name == "Tracer_Cli" })
+	if err != nil || len(sorted) != 1 {
+		t.Fatalf("unexpected %v %v", sorted, err)
+	}
+}
+
+func TestSortCreatorsCycle(t *testing.T) {
+	w := New()
+	w.AddModCreator("A", 0, noopCreator, "B")
+	w.AddModCreator("B", 0, noopCreator, "C")
+	w.AddModCreator("C", 0, noopCreator, "A")
+	w.AddModCreator("D", 0, noopCreator)
+
+	_, err := w.sortCreators(noneRegistered)
+	if err == nil || !strings.Contains(err.Error(), "A -> B -> C -> A") {
+		t.Fatalf("expect cycle error, got %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package waitgroup
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/DoOR-Team/goutils/log"
+)
+
+// Run 退出时的进程状态码
+const (
+	ExitOK     = 0 // 收到信号后正常关闭
+	ExitError  = 1 // 初始化失败、Serve 异常退出或者关闭出错
+	ExitForced = 2 // 关闭过程中再次收到信号，强制退出
+)
+
+type runOptions struct {
+	gracePeriod     time.Duration
+	shutdownTimeout time.Duration
+	closeOpts       []CloseOption
+	signals         []os.Signal
+	// 强制退出时调用，测试时替换
+	exit func(code int)
+}
+
+type RunOption func(*runOptions)
+
+// 收到信号后先进入 draining 状态等待一段时间，让负载均衡摘掉流量后再关闭模块
+func GracePeriod(d time.Duration) RunOption {
+	return func(options *runOptions) {
+		options.gracePeriod = d
+	}
+}
+
+// 关闭所有模块的总超时时间，0 表示一直等待
+func ShutdownTimeout(d time.Duration) RunOption {
+	return func(options *runOptions) {
+		options.shutdownTimeout = d
+	}
+}
+
+func WithCloseOptions(opts ...CloseOption) RunOption {
+	return func(options *runOptions) {
+		options.closeOpts = append(options.closeOpts, opts...)
+	}
+}
+
+// 监听的信号，默认 SIGTERM 和 SIGINT
+func Signals(sigs ...os.Signal) RunOption {
+	return func(options *runOptions) {
+		options.signals = sigs
+	}
+}
+
+func (w *Registry) IsDraining() bool {
+	return atomic.LoadInt32(&w.draining) == 1
+}
+
+// 初始化所有模块并阻塞，直到收到退出信号或者某个 Serve 异常退出，之后按顺序关闭模块
+// 返回进程应使用的退出码
+func (w *Registry) Run(opts ...RunOption) int {
+	options := &runOptions{
+		signals: []os.Signal{syscall.SIGTERM, syscall.SIGINT},
+		exit:    os.Exit,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, options.signals...)
+	defer signal.Stop(sigCh)
+
+	failCh := make(chan struct{}, 1)
+	err := w.InitModsAndWrapServersWithExitFunc(func() {
+		select {
+		case failCh <- struct{}{}:
+		default:
+		}
+	})
+	if err != nil {
+		log.Errorf("[wait group] 初始化失败 - %v", err)
+		return ExitError
+	}
+
+	code := ExitOK
+	select {
+	case sig := <-sigCh:
+		log.Noticef("[wait group] 收到信号 %v，开始关闭", sig)
+	case <-failCh:
+		log.Error("[wait group] 有模块异常退出，开始关闭")
+		code = ExitError
+	}
+
+	atomic.StoreInt32(&w.draining, 1)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case sig := <-sigCh:
+			log.Errorf("[wait group] 关闭过程中再次收到信号 %v，强制退出", sig)
+			options.exit(ExitForced)
+		case <-done:
+		}
+	}()
+
+	if options.gracePeriod > 0 {
+		log.Noticef("[wait group] draining，%v 后关闭模块", options.gracePeriod)
+		time.Sleep(options.gracePeriod)
+	}
+
+	ctx := context.Background()
+	if options.shutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.shutdownTimeout)
+		defer cancel()
+	}
+	if err := w.CloseModsAndWaitCtx(ctx, options.closeOpts...); err != nil {
+		log.Errorf("[wait group] 关闭失败 - %v", err)
+		code = ExitError
+	}
+	return code
+}
+
+// 是否已经收到退出信号，正在 draining
+func IsDraining() bool {
+	return defaultRegistry.IsDraining()
+}
+
+// 服务的统一入口，替代自己处理信号再调用 InitModsAndWrapServersWithExitFunc/CloseModsAndWait
+func Run(opts ...RunOption) {
+	os.Exit(defaultRegistry.Run(opts...))
+}
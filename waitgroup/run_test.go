@@ -0,0 +1,87 @@
+//go:build !windows
+// +build !windows
+
+package waitgroup
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRunSignal(t *testing.T) {
+	w := New()
+	stop := make(chan struct{})
+	_ = w.AddModAndWrapServer("HTTP_Server", &Srv{
+		ServeFunc: func() error {
+			<-stop
+			return nil
+		},
+		CloseFunc: func() error {
+			close(stop)
+			return nil
+		},
+	})
+
+	codeCh := make(chan int)
+	go func() {
+		codeCh <- w.Run(GracePeriod(50 * time.Millisecond))
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	if w.IsDraining() {
+		t.Fatal("should not be draining before signal")
+	}
+	_ = syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+	time.Sleep(20 * time.Millisecond)
+	if !w.IsDraining() {
+		t.Fatal("expect draining after signal")
+	}
+
+	select {
+	case code := <-codeCh:
+		if code != ExitOK {
+			t.Fatalf("expect ExitOK, got %d", code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run not returned")
+	}
+}
+
+func TestRunServeFailed(t *testing.T) {
+	w := New()
+	_ = w.AddModAndWrapServer("Metrics_Server", &Srv{
+		ServeFunc: func() error {
+			time.Sleep(20 * time.Millisecond)
+			return errors.New("listen failed")
+		},
+		CloseFunc: func() error { return nil },
+	})
+
+	if code := w.Run(); code != ExitError {
+		t.Fatalf("expect ExitError, got %d", code)
+	}
+}
+
+func TestRunForceExit(t *testing.T) {
+	w := New()
+	forced := make(chan int, 1)
+	go w.Run(GracePeriod(time.Hour), func(options *runOptions) {
+		options.exit = func(code int) { forced <- code }
+	})
+
+	time.Sleep(20 * time.Millisecond)
+	_ = syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+	time.Sleep(20 * time.Millisecond)
+	_ = syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+
+	select {
+	case code := <-forced:
+		if code != ExitForced {
+			t.Fatalf("expect ExitForced, got %d", code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("second signal did not force exit")
+	}
+}
@@ -0,0 +1,252 @@
+package waitgroup
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+
+	"github.com/DoOR-Team/goutils/log"
+)
+
+type closeOptions struct {
+	timeout     time.Duration
+	modTimeouts map[string]time.Duration
+	parallel    bool
+}
+
+type CloseOption func(*closeOptions)
+
+// 每个模块 Close 的默认超时时间，超时后不再等待该模块，继续关闭后面的模块
+func CloseTimeout(timeout time.Duration) CloseOption {
+	return func(options *closeOptions) {
+		options.timeout = timeout
+	}
+}
+
+// 单独指定某个模块 Close 的超时时间，优先级高于 CloseTimeout
+func ModCloseTimeout(name string, timeout time.Duration) CloseOption {
+	return func(options *closeOptions) {
+		if options.modTimeouts == nil {
+			options.modTimeouts = map[string]time.Duration{}
+		}
+		options.modTimeouts[name] = timeout
+	}
+}
+
+// 没有依赖关系的模块并行关闭，依赖其他模块的模块仍然会先关闭
+func ParallelClose() CloseOption {
+	return func(options *closeOptions) {
+		options.parallel = true
+	}
+}
+
+func (o *closeOptions) timeoutFor(name string) time.Duration {
+	if t, ok := o.modTimeouts[name]; ok {
+		return t
+	}
+	return o.timeout
+}
+
+// ctx 结束时仍未退出的模块
+type ShutdownTimeoutError struct {
+	Closing []string // 还未关闭的模块，包括 Close 还未返回或者还没来得及调用的
+	Serving []string // Serve 还未返回的模块
+}
+
+func (e *ShutdownTimeoutError) Error() string {
+	return fmt.Sprintf("[wait group] shutdown deadline exceeded, closing: [%s], serving: [%s]",
+		strings.Join(e.Closing, ", "), strings.Join(e.Serving, ", "))
+}
+
+func (w *Registry) markServing(name string, serving bool) {
+	w.stateMu.Lock()
+	defer w.stateMu.Unlock()
+	if serving {
+		w.serving[name] = struct{}{}
+	} else {
+		delete(w.serving, name)
+	}
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (w *Registry) closeMods(ctx context.Context, options *closeOptions) error {
+	w.stateMu.Lock()
+	if w.closed {
+		w.stateMu.Unlock()
+		return fmt.Errorf("[wait group] Mods is already closed")
+	}
+	if !w.inited {
+		w.stateMu.Unlock()
+		return fmt.Errorf("[wait group] Please init first")
+	}
+	w.closed = true
+	close(w.closedCh)
+	// 此后不会再有模块加入
+	names := append([]string{}, w.names...)
+	deps := w.deps
+	w.stateMu.Unlock()
+
+	if options.parallel {
+		return w.closeModsParallel(ctx, options, names, deps)
+	}
+
+	var errs error
+	for index := len(names) - 1; index >= 0; index-- {
+		if ctx.Err() != nil {
+			break
+		}
+		name := names[index]
+		errs = multierr.Append(errs, w.closeMod(ctx, name, options.timeoutFor(name)))
+	}
+	return errs
+}
+
+func (w *Registry) closeModsParallel(ctx context.Context, options *closeOptions, names []string, deps map[string][]string) error {
+	// 依赖 name 的模块都关闭（或超时）后，name 才能开始关闭
+	dependents := map[string][]string{}
+	dones := map[string]chan struct{}{}
+	for _, name := range names {
+		dones[name] = make(chan struct{})
+		for _, dep := range deps[name] {
+			dependents[dep] = append(dependents[dep], name)
+		}
+	}
+
+	var (
+		errsMu sync.Mutex
+		errs   error
+		wg     sync.WaitGroup
+	)
+	for _, name := range names {
+		name := name
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(dones[name])
+
+			for _, d := range dependents[name] {
+				select {
+				case <-dones[d]:
+				case <-ctx.Done():
+					return
+				}
+			}
+			err := w.closeMod(ctx, name, options.timeoutFor(name))
+
+			errsMu.Lock()
+			errs = multierr.Append(errs, err)
+			errsMu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+func (w *Registry) closeMod(ctx context.Context, name string, timeout time.Duration) error {
+	w.stateMu.Lock()
+	mod, ok := w.mods[name]
+	w.stateMu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	if err := w.runHooks(w.hooksOf(hookBeforeClose), name, mod); err != nil {
+		log.Errorf("[wait group] 模块 %s 关闭前回调失败 - %v", name, err)
+	}
+	w.setState(name, StateClosing)
+
+	done := make(chan error, 1)
+	go func() {
+		err := mod.Close()
+		if err != nil {
+			w.setState(name, StateFailed)
+		} else {
+			w.setState(name, StateClosed)
+		}
+		if herr := w.runHooks(w.hooksOf(hookAfterClose), name, mod); herr != nil {
+			log.Errorf("[wait group] 模块 %s 关闭后回调失败 - %v", name, herr)
+		}
+		done <- err
+	}()
+
+	var timer <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		timer = t.C
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			log.Errorf("[wait group] %s 模块关闭失败 - %v", name, err)
+			return err
+		}
+		log.Infof("[wait group] %s mod closed", name)
+		return nil
+	case <-timer:
+		log.Errorf("[wait group] %s 模块关闭超时(%v)", name, timeout)
+		return fmt.Errorf("[wait group] Mod %s close timeout after %v", name, timeout)
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// 关闭所有模块并等待所有 Serve 返回，ctx 结束时不再等待，返回的错误中包含 *ShutdownTimeoutError
+func (w *Registry) CloseModsAndWaitCtx(ctx context.Context, opts ...CloseOption) error {
+	options := &closeOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	errs := w.closeMods(ctx, options)
+
+	done := make(chan struct{})
+	go func() {
+		w.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	if ctx.Err() == nil {
+		return errs
+	}
+
+	w.stateMu.Lock()
+	unclosed := map[string]struct{}{}
+	for _, name := range w.names {
+		if state := w.states[name]; state != StateClosed && state != StateFailed {
+			unclosed[name] = struct{}{}
+		}
+	}
+	timeoutErr := &ShutdownTimeoutError{
+		Closing: sortedKeys(unclosed),
+		Serving: sortedKeys(w.serving),
+	}
+	w.stateMu.Unlock()
+	if len(timeoutErr.Closing) == 0 && len(timeoutErr.Serving) == 0 {
+		return errs
+	}
+	log.Error(timeoutErr)
+	return multierr.Append(errs, timeoutErr)
+}
+
+func CloseModsAndWaitCtx(ctx context.Context, opts ...CloseOption) error {
+	return defaultRegistry.CloseModsAndWaitCtx(ctx, opts...)
+}
@@ -0,0 +1,94 @@
+package waitgroup
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+type recorder struct {
+	mu     sync.Mutex
+	closed []string
+}
+
+func (r *recorder) cli(name string, delay time.Duration) *Cli {
+	return &Cli{CloseFunc: func() error {
+		time.Sleep(delay)
+		r.mu.Lock()
+		r.closed = append(r.closed, name)
+		r.mu.Unlock()
+		return nil
+	}}
+}
+
+func (r *recorder) order() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return strings.Join(r.closed, ",")
+}
+
+func TestCloseModTimeout(t *testing.T) {
+	r := &recorder{}
+	w := New()
+	_ = w.AddModAndWrapServer("A", r.cli("A", 0))
+	_ = w.AddModAndWrapServer("Hung", r.cli("Hung", time.Hour))
+	_ = w.AddModAndWrapServer("C", r.cli("C", 0))
+	w.inited = true
+
+	err := w.CloseModsAndWaitCtx(context.Background(), ModCloseTimeout("Hung", 20*time.Millisecond))
+	if err == nil || !strings.Contains(err.Error(), "Hung") {
+		t.Fatalf("expect Hung timeout, got %v", err)
+	}
+	if got := r.order(); got != "C,A" {
+		t.Fatalf("unexpected close order %s", got)
+	}
+}
+
+func TestCloseModsDeadline(t *testing.T) {
+	w := New()
+	stop := make(chan struct{})
+	defer close(stop)
+	_ = w.AddModAndWrapServer("Stuck_Server", &Srv{
+		ServeFunc: func() error {
+			<-stop
+			return nil
+		},
+		CloseFunc: func() error { return nil },
+	})
+	w.inited = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := w.CloseModsAndWaitCtx(ctx)
+
+	var timeoutErr *ShutdownTimeoutError
+	for _, e := range multierr.Errors(err) {
+		if te, ok := e.(*ShutdownTimeoutError); ok {
+			timeoutErr = te
+		}
+	}
+	if timeoutErr == nil || len(timeoutErr.Serving) != 1 || timeoutErr.Serving[0] != "Stuck_Server" {
+		t.Fatalf("expect Stuck_Server still serving, got %v", err)
+	}
+}
+
+func TestCloseModsParallel(t *testing.T) {
+	r := &recorder{}
+	w := New()
+	_ = w.AddModAndWrapServer("Tracer_Cli", r.cli("Tracer_Cli", 0))
+	_ = w.AddModAndWrapServer("GORM_Client", r.cli("GORM_Client", 30*time.Millisecond), "Tracer_Cli")
+	_ = w.AddModAndWrapServer("Other", r.cli("Other", 0))
+	w.inited = true
+
+	if err := w.CloseModsAndWaitCtx(context.Background(), ParallelClose()); err != nil {
+		t.Fatal(err)
+	}
+	// Other 与其他模块无依赖，可以先关；Tracer_Cli 必须等 GORM_Client
+	if got := r.order(); got != "Other,GORM_Client,Tracer_Cli" {
+		t.Fatalf("unexpected close order %s", got)
+	}
+}
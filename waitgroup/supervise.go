@@ -0,0 +1,142 @@
+package waitgroup
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/DoOR-Team/goutils/alert"
+	"github.com/DoOR-Team/goutils/log"
+)
+
+type RestartMode int
+
+const (
+	// Serve 返回错误时结束进程（默认行为）
+	RestartNever RestartMode = iota
+	// Serve 无论是否返回错误都重启
+	RestartAlways
+	// 仅 Serve 返回错误时重启
+	RestartOnFailure
+)
+
+const (
+	defaultRestartBackoff    = time.Second
+	defaultRestartMaxBackoff = time.Minute
+)
+
+type RestartPolicy struct {
+	Mode RestartMode
+	// 最多重启次数，0 表示不限制；超过后按 RestartNever 处理
+	MaxRestarts int
+	// 第一次重启前的等待时间，之后每次翻倍，默认 1s
+	Backoff time.Duration
+	// 等待时间上限，默认 1min
+	MaxBackoff time.Duration
+}
+
+// Server 模块可选实现，用于指定 Serve 退出后的重启策略
+type RestartPolicyProvider interface {
+	RestartPolicy() RestartPolicy
+}
+
+func (h *Srv) RestartPolicy() RestartPolicy {
+	return h.Policy
+}
+
+func restartPolicyOf(mod Mod) RestartPolicy {
+	if p, ok := mod.(RestartPolicyProvider); ok {
+		return p.RestartPolicy()
+	}
+	return RestartPolicy{}
+}
+
+// 已经重启 restarts 次、这次 Serve 返回 err 时是否需要重启
+func (p RestartPolicy) ShouldRestart(err error, restarts int) bool {
+	if p.MaxRestarts > 0 && restarts >= p.MaxRestarts {
+		return false
+	}
+	switch p.Mode {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return err != nil
+	}
+	return false
+}
+
+// 第 n 次重启前的等待时间
+func (p RestartPolicy) NextBackoff(n int) time.Duration {
+	d := p.Backoff
+	if d <= 0 {
+		d = defaultRestartBackoff
+	}
+	max := p.MaxBackoff
+	if max <= 0 {
+		max = defaultRestartMaxBackoff
+	}
+	for i := 1; i < n && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+// 重启时的报警，测试时替换
+var restartAlert = func(name string, text string) {
+	go func() {
+		if err := alert.AlertDingMsgWithConfig(name, text, alert.AutoFire()); err != nil {
+			log.Error(err)
+		}
+	}()
+}
+
+// 按重启策略运行 Serve，返回是否因为模块关闭而退出以及最后一次 Serve 的错误
+func (w *Registry) supervise(name string, svc Server) (closed bool, err error) {
+	policy := restartPolicyOf(svc)
+	restarts := 0
+	for {
+		w.stateMu.Lock()
+		if !w.closed {
+			w.states[name] = StateServing
+		}
+		w.stateMu.Unlock()
+		err = svc.Serve()
+		if w.isClosed() {
+			return true, err
+		}
+		if !policy.ShouldRestart(err, restarts) {
+			if err != nil {
+				w.setState(name, StateFailed)
+			} else {
+				w.setState(name, StateClosed)
+			}
+			return false, err
+		}
+
+		restarts++
+		w.stateMu.Lock()
+		w.restarts[name] = restarts
+		w.states[name] = StateStarting
+		w.stateMu.Unlock()
+
+		wait := policy.NextBackoff(restarts)
+		msg := fmt.Sprintf("模块 %s 退出(err: %v)，%v 后第 %d 次重启", name, err, wait, restarts)
+		log.Warnf("[wait group] %s", msg)
+		restartAlert(name, msg)
+
+		select {
+		case <-time.After(wait):
+		case <-w.closedCh:
+			return true, err
+		}
+		log.Infof("[wait group] 模块 %s 重启", name)
+	}
+}
+
+func (w *Registry) isClosed() bool {
+	w.stateMu.Lock()
+	defer w.stateMu.Unlock()
+	return w.closed
+}
@@ -0,0 +1,83 @@
+package waitgroup
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRestartBackoff(t *testing.T) {
+	p := RestartPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	expects := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for i, expect := range expects {
+		if got := p.NextBackoff(i + 1); got != expect {
+			t.Fatalf("restart %d: expect %v, got %v", i+1, expect, got)
+		}
+	}
+}
+
+func TestRestartOnFailure(t *testing.T) {
+	restartAlert = func(string, string) {}
+
+	w := New()
+	exited := make(chan struct{})
+	w.exit = func() { close(exited) }
+
+	var serveCnt int32
+	_ = w.AddModAndWrapServer("Metrics_Server", &Srv{
+		ServeFunc: func() error {
+			atomic.AddInt32(&serveCnt, 1)
+			return errors.New("listen failed")
+		},
+		CloseFunc: func() error { return nil },
+		Policy: RestartPolicy{
+			Mode:        RestartOnFailure,
+			MaxRestarts: 3,
+			Backoff:     time.Millisecond,
+		},
+	})
+
+	select {
+	case <-exited:
+	case <-time.After(time.Second):
+		t.Fatal("expect exit after max restarts")
+	}
+	if n := atomic.LoadInt32(&serveCnt); n != 4 {
+		t.Fatalf("expect serve 4 times, got %d", n)
+	}
+	if r := w.Liveness(); r.Mods["Metrics_Server"].Restarts != 3 {
+		t.Fatalf("expect 3 restarts, got %+v", r.Mods["Metrics_Server"])
+	}
+}
+
+func TestRestartStopsOnClose(t *testing.T) {
+	restartAlert = func(string, string) {}
+
+	w := New()
+	w.exit = func() { t.Error("should not exit after close") }
+	_ = w.AddModAndWrapServer("RMQ_Pull", &Srv{
+		ServeFunc: func() error {
+			return nil
+		},
+		CloseFunc: func() error { return nil },
+		Policy:    RestartPolicy{Mode: RestartAlways, Backoff: time.Hour},
+	})
+	w.inited = true
+
+	time.Sleep(10 * time.Millisecond)
+	if err := w.CloseMods(); err != nil {
+		t.Fatal(err)
+	}
+	// 关闭后不应该再等待 backoff
+	done := make(chan struct{})
+	go func() {
+		w.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("supervisor still waiting after close")
+	}
+}
@@ -1,18 +1,21 @@
 package waitgroup
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"sync"
 
-	"go.uber.org/multierr"
-
 	"github.com/DoOR-Team/goutils/log"
 )
 
 type Srv struct {
 	CloseFunc func() error
 	ServeFunc func() error
+	// 可选，用于就绪检查
+	CheckFunc func(ctx context.Context) error
+	// 可选，Serve 退出后的重启策略，默认出错时结束进程
+	Policy RestartPolicy
 }
 
 func (h *Srv) Close() error {
@@ -23,14 +26,30 @@ func (h *Srv) Serve() error {
 	return h.ServeFunc()
 }
 
+func (h *Srv) Check(ctx context.Context) error {
+	if h.CheckFunc == nil {
+		return nil
+	}
+	return h.CheckFunc(ctx)
+}
+
 type Cli struct {
 	CloseFunc func() error
+	// 可选，用于就绪检查
+	CheckFunc func(ctx context.Context) error
 }
 
 func (c *Cli) Close() error {
 	return c.CloseFunc()
 }
 
+func (c *Cli) Check(ctx context.Context) error {
+	if c.CheckFunc == nil {
+		return nil
+	}
+	return c.CheckFunc(ctx)
+}
+
 type Mod interface {
 	io.Closer
 }
@@ -52,135 +71,207 @@ func (*NoopMod) Close() error {
 	return nil
 }
 
-var defaultWaitGroupWrapper = waitGroupWrapper{
-	mods: make(map[string]Mod),
+// 包级别的函数都作用在这个默认的 Registry 上
+var defaultRegistry = New()
+
+// 模块注册表，管理模块的初始化、运行以及关闭；测试时可以各自 New 一个互不影响
+type Registry struct {
+	wg sync.WaitGroup
+
+	creatorMu sync.Mutex
+	creators  map[string]*creatorWrapper
+
+	// 以下字段都由 stateMu 保护
+	stateMu sync.Mutex
+	mods    map[string]Mod
+	names   []string
+	deps    map[string][]string
+	states  map[string]ModState
+	// Serve 还未返回的模块，关闭超时的时候用来定位
+	serving  map[string]struct{}
+	restarts map[string]int
+	hooks    hooks
+	// closeMods 时关闭，用于打断重启前的等待
+	closedCh chan struct{}
+
+	inited   bool
+	closed   bool
+	draining int32
+
+	exit func()
 }
 
-func (w *waitGroupWrapper) InitModsAndWrapServersWithExitFunc(exit func()) error {
-	if w.closed {
+func New() *Registry {
+	return &Registry{
+		creators: make(map[string]*creatorWrapper),
+		mods:     make(map[string]Mod),
+		deps:     make(map[string][]string),
+		states:   make(map[string]ModState),
+		serving:  make(map[string]struct{}),
+		restarts: make(map[string]int),
+		closedCh: make(chan struct{}),
+	}
+}
+
+// 返回包级别函数使用的默认 Registry
+func Default() *Registry {
+	return defaultRegistry
+}
+
+func (w *Registry) InitModsAndWrapServersWithExitFunc(exit func()) error {
+	w.stateMu.Lock()
+	closed, inited := w.closed, w.inited
+	w.stateMu.Unlock()
+	if closed {
 		log.Error("[waitgroup] waitgroup已经关闭，无法启动")
 		return fmt.Errorf("[waitgroup] Cant init after closed")
 	}
-	if w.inited {
+	if inited {
 		log.Error("[waitgroup] waitgroup已经被初始化")
 		return fmt.Errorf("[waitgroup] Cant init mods twice")
 	}
 
+	w.stateMu.Lock()
 	w.exit = exit
+	w.stateMu.Unlock()
 
-	sorts := sortCreators(true)
+	sorts, err := w.sortCreators(w.hasMod)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
 	for _, cw := range sorts {
 		name := cw.name
 		mod := cw.c()
 		if mod == nil {
 			log.Error("[waitgroup] 模块获取失败")
+			w.setState(name, StateFailed)
 			return fmt.Errorf("[waitgroup] Can't returns nil mod")
 		}
 		log.Notice("[waitgroup] 初始化模块：", name)
-		err := w.addModAndWrapServer(name, mod)
+		err := w.addModAndWrapServer(name, mod, cw.deps...)
 		if err != nil {
 			return err
 		}
 	}
 
+	w.stateMu.Lock()
 	w.inited = true
+	w.stateMu.Unlock()
 	return nil
 }
 
-type waitGroupWrapper struct {
-	sync.WaitGroup
-
-	mods  map[string]Mod
-	names []string
-
-	inited bool
-	closed bool
-
-	exit func()
-}
-
 func InitModsAndWrapServersWithExitFunc(exit func()) error {
-	err := defaultWaitGroupWrapper.InitModsAndWrapServersWithExitFunc(exit)
+	err := defaultRegistry.InitModsAndWrapServersWithExitFunc(exit)
 	if err != nil {
 		panic(err)
 	}
 	return err
 }
 
-func (w *waitGroupWrapper) AddModAndWrapServer(name string, mod Mod) error {
-	return w.addModAndWrapServer(name, mod)
+func (w *Registry) hasMod(name string) bool {
+	w.stateMu.Lock()
+	defer w.stateMu.Unlock()
+	_, ok := w.mods[name]
+	return ok
+}
+
+// deps 为依赖的模块名，必须已经注册；关闭时本模块会先于 deps 关闭
+func (w *Registry) AddModAndWrapServer(name string, mod Mod, deps ...string) error {
+	return w.addModAndWrapServer(name, mod, deps...)
 }
 
-func (w *waitGroupWrapper) addModAndWrapServer(name string, mod Mod) error {
+func (w *Registry) addModAndWrapServer(name string, mod Mod, deps ...string) error {
+	w.stateMu.Lock()
 	if _, ok := w.mods[name]; ok {
+		w.stateMu.Unlock()
 		return fmt.Errorf("[wait group] Mod %s already exists", name)
 	}
+	for _, dep := range deps {
+		if _, ok := w.mods[dep]; !ok {
+			w.stateMu.Unlock()
+			return fmt.Errorf("[wait group] Mod %s depends on missing mod %s", name, dep)
+		}
+	}
+	w.stateMu.Unlock()
 
+	if err := w.runHooks(w.hooksOf(hookBeforeStart), name, mod); err != nil {
+		w.setState(name, StateFailed)
+		log.Errorf("[wait group] 模块 %s 启动前回调失败 - %v", name, err)
+		return err
+	}
+
+	w.stateMu.Lock()
 	w.mods[name] = mod
 	w.names = append(w.names, name)
+	w.deps[name] = deps
+	w.states[name] = StateStarting
+	w.stateMu.Unlock()
 
 	svc, ok := mod.(Server)
 	if ok {
+		w.markServing(name, true)
 		w.Wrap(func() {
+			defer w.markServing(name, false)
 			log.Infof("[wait group] 模块 %s 初始化成功", name)
-			if err := svc.Serve(); err != nil {
+			if closed, err := w.supervise(name, svc); err != nil && !closed {
 				//如果还未closed就执行外界提供的结束进程方法
 				//这个主要是为了防止死锁，很多服务的Close方法执行后这里会抛一个错误出来
 				//然后再调用exit，exit里调用CloseServices内部就会死锁
-				if !w.closed && w.exit != nil {
+				if exit := w.exitFunc(); exit != nil {
 					log.Errorf("[wait group] 模块 %s 初始化错误 err: %v", name, err)
-					w.exit()
+					exit()
 				}
 			}
 		})
+	} else {
+		w.setState(name, StateServing)
 	}
 
+	if err := w.runHooks(w.hooksOf(hookAfterStart), name, mod); err != nil {
+		log.Errorf("[wait group] 模块 %s 启动后回调失败 - %v", name, err)
+		return err
+	}
 	return nil
 }
 
-func (w *waitGroupWrapper) CloseMods() error {
+// 已经 closed 时返回 nil
+func (w *Registry) exitFunc() func() {
+	w.stateMu.Lock()
+	defer w.stateMu.Unlock()
 	if w.closed {
-		return fmt.Errorf("[wait group] Mods is already closed")
+		return nil
 	}
+	return w.exit
+}
 
-	if !w.inited {
-		return fmt.Errorf("[wait group] Please init first")
-	}
-	w.closed = true
-
-	var errs error
-	for index := len(w.names) - 1; index >= 0; index-- {
-		name := w.names[index]
-		mod, ok := w.mods[name]
-		if !ok {
-			continue
-		}
-
-		err := mod.Close()
-		if err != nil {
-			errs = multierr.Append(errs, err)
-			log.Errorf("[wait group] %s 模块关闭失败 - %v", name, err)
-		} else {
-			log.Infof("[wait group] %s mod closed", name)
-		}
-	}
-	return errs
+func (w *Registry) CloseMods() error {
+	return w.closeMods(context.Background(), &closeOptions{})
 }
 
-func (w *waitGroupWrapper) Wrap(cb func()) {
-	w.Add(1)
+func (w *Registry) Wrap(cb func()) {
+	w.wg.Add(1)
 	go func() {
 		cb()
-		w.Done()
+		w.wg.Done()
 	}()
 }
 
-func CloseModsAndWait() error {
-	err := defaultWaitGroupWrapper.CloseMods()
-	defaultWaitGroupWrapper.Wait()
+// 等待所有 Serve 返回
+func (w *Registry) Wait() {
+	w.wg.Wait()
+}
+
+func (w *Registry) CloseModsAndWait() error {
+	err := w.CloseMods()
+	w.Wait()
 	return err
 }
 
-func AddModAndWrapServer(name string, mod Mod) error {
-	return defaultWaitGroupWrapper.AddModAndWrapServer(name, mod)
+func CloseModsAndWait() error {
+	return defaultRegistry.CloseModsAndWait()
+}
+
+func AddModAndWrapServer(name string, mod Mod, deps ...string) error {
+	return defaultRegistry.AddModAndWrapServer(name, mod, deps...)
 }